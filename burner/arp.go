@@ -0,0 +1,84 @@
+package burner
+
+import (
+	"image_burner/driver"
+	"image_burner/ping"
+	"image_burner/util"
+	"os"
+	"runtime"
+	"time"
+)
+
+var Arp_candidates = false // probe only hosts in the arp table
+var Ping_sweep = false     // ping every host first so the arp table is full
+
+// one echo to each host, replies do not matter, only the arp entries they leave
+func ping_sweep(hosts []string) {
+	privileged := runtime.GOOS == "windows" || os.Geteuid() == 0
+	pool := oakUtility.New_Pool(Scan_concurrency, Scan_rate)
+	defer pool.Close()
+	for _, h := range hosts {
+		host := h
+		pool.Submit(func() {
+			p, err := ping.NewPinger(host)
+			if err != nil {
+				return
+			}
+			p.Count = 1
+			p.Timeout = time.Second
+			p.SetPrivileged(privileged)
+			p.Run()
+		})
+	}
+	pool.Wait()
+}
+
+// arp_candidates keeps hosts in the arp table. Those of a vendor we flash
+// come first and are hints, the others are probed too since QTS and
+// Oakridge branded prefixes are not all known; unknown counts them.
+func arp_candidates(hosts []string) (picked []string, hints map[string]oakUtility.Neighbor, unknown int) {
+	neighbors, err := oakUtility.Read_neighbors()
+	if err != nil {
+		log.Error.Printf("arp table: %s\n", err.Error())
+		return hosts, nil, 0
+	}
+	by_ip := map[string]oakUtility.Neighbor{}
+	for _, n := range neighbors {
+		by_ip[n.IPv4] = n
+	}
+
+	var others []string
+	hints = map[string]oakUtility.Neighbor{}
+	for _, h := range hosts {
+		n, ok := by_ip[h]
+		if !ok {
+			continue
+		}
+		if oakUtility.Oui_vendor(n.Mac) == "" {
+			log.Debug.Printf("%s %s: unknown vendor\n", h, n.Mac)
+			others = append(others, h)
+			continue
+		}
+		picked = append(picked, h)
+		hints[h] = n
+	}
+	return append(picked, others...), hints, len(others)
+}
+
+// hosts with a known vendor mac which no driver got into still show up
+func (s *Subnet) merge_hints(hints map[string]oakUtility.Neighbor) {
+	devs := s.Devs.List()
+	for ip, n := range hints {
+		known := false
+		for _, d := range devs {
+			if d.IPv4 == ip {
+				d.Fill(&driver.Device{Mac: n.Mac})
+				known = true
+				break
+			}
+		}
+		if !known {
+			s.Devs.Add(driver.From_neighbor(n, oakUtility.Oui_vendor(n.Mac)))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package burner is what every oakburn command shares: options, scanning,
+// device selection and reports. Model specific work stays in driver.
+package burner
+
+import (
+	"flag"
+	"fmt"
+	"image_burner/driver"
+	"image_burner/util"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Banner_start = `
+Oakridge Firmware Utility, Ver 1.10, (c) Oakridge Networks, Inc. 2018
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+NOTE:
+1. Make sure AP in the scanned subnet, or can directly input the target subnet
+e.g. scan 192.168.1.0/24 subnet: ./oakburn convert 192.168.1.0/24
+2. Make sure AP is reset back to factory default state
+~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+`
+const Banner_end = "\nThanks for choose Oakridge Networks Inc.\n"
+
+var log = oakUtility.New_OakLogger()
+
+func Set_log_level(level string) {
+	log.Set_level(level)
+	driver.Set_log_level(level)
+}
+
+// Options are flags common to all commands
+type Options struct {
+	Catalog_file string
+	Config_file  string
+	Image_server string
+	Mirrors      string
+	No_verify    bool
+	Bundle_file  string
+	Credentials  string // credentials file
+	Known_hosts  string
+	Host_key     string // host key policy
+	Rotate_keys  bool
+	No_sftp      bool          // no sftp where scp fails
+	Verify_wait  time.Duration // post flash verification
+	Select       string        // device selection, "" means menu
+	Yes          bool
+	Journal      string // job journal file
+	Resume       bool   // unfinished jobs of the journal
+	Output       string // text, json or ndjson
+	Concurrency  int
+	Rate         int
+	Ports        string // comma separated ssh ports to probe
+	Probe        time.Duration
+	No_discovery bool
+	Unicast      bool // discovery to every host too
+	Arp          bool
+	Ping         bool
+}
+
+// Add_flags registers flags all commands share on fs
+func Add_flags(fs *flag.FlagSet) *Options {
+	o := &Options{Output: OUTPUT_TEXT, Concurrency: Scan_concurrency, Rate: Scan_rate, Probe: Probe_timeout, Verify_wait: driver.Verify_timeout}
+	fs.StringVar(&o.Catalog_file, "catalog", "", "model catalog `file`, default is the built-in one")
+	fs.StringVar(&o.Config_file, "config", "", "config `file`, default ~/.oakridge/config.json")
+	fs.StringVar(&o.Image_server, "image-server", "", "image server `url` for relative catalog urls, env OAK_IMAGE_SERVER")
+	fs.StringVar(&o.Mirrors, "mirrors", "", "comma separated mirror `urls` tried in order, env OAK_IMAGE_MIRRORS")
+	fs.BoolVar(&o.No_verify, "no-verify", false, "skip image checksum, only for image servers without SHA256SUMS")
+	fs.StringVar(&o.Bundle_file, "bundle", "", "offline bundle `file`, serve all images from it without network")
+	fs.StringVar(&o.Credentials, "credentials", "", "ssh credentials `file` tried before factory logins, env OAK_CREDENTIALS")
+	fs.StringVar(&o.Known_hosts, "known-hosts", "", "pinned host keys `file`, default ~/.oakridge/known_hosts")
+	fs.StringVar(&o.Host_key, "host-key", oakUtility.HOSTKEY_WARN, "`off|warn|strict` on a host key which changed since first contact")
+	fs.BoolVar(&o.No_sftp, "no-sftp", false, "fail an image upload where scp fails, do not retry it over sftp")
+	fs.BoolVar(&o.Rotate_keys, "rotate-host-keys", false, "forget pinned host keys of devices flashed in this run, new firmware has new keys")
+	return o
+}
+
+// Scan_flags for commands which scan subnets
+func (o *Options) Scan_flags(fs *flag.FlagSet) {
+	fs.IntVar(&o.Concurrency, "concurrency", Scan_concurrency, "probe at most `n` hosts at the same time")
+	fs.IntVar(&o.Rate, "rate", Scan_rate, "start at most `n` host probes a second per subnet, 0 no limit")
+	fs.StringVar(&o.Ports, "ports", strings.Join(Scan_ports, ","), "comma separated ssh `ports` to probe, first open one is used")
+	fs.DurationVar(&o.Probe, "probe-timeout", Probe_timeout, "tcp connect `timeout` of port probe")
+	fs.BoolVar(&o.No_discovery, "no-discovery", false, "skip Ubiquiti discovery (udp 10001) next to the ssh sweep")
+	fs.BoolVar(&o.Unicast, "discovery-unicast", false, "send Ubiquiti discovery to every host too, not only as broadcast, paced like the ssh probes")
+	fs.BoolVar(&o.Arp, "arp", false, "only probe hosts in the arp table, those of a mac vendor we flash first")
+	fs.BoolVar(&o.Ping, "ping", false, "with --arp, ping sweep subnets first to fill the arp table")
+}
+
+// Output_flags for commands which report devices
+func (o *Options) Output_flags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Output, "output", OUTPUT_TEXT, "`text|json|ndjson`, json and ndjson print devices and job results as records on stdout")
+}
+
+// Select_flags for commands which change devices
+func (o *Options) Select_flags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Select, "select", "", "work on matching devices without menu: `all|mac=..|model=..|ip=cidr`, comma separated terms must all match")
+	fs.BoolVar(&o.Yes, "yes", false, "do not ask for confirmation, same as --select all if no --select")
+	fs.StringVar(&o.Journal, "journal", "", "job journal `file`, default a new one per run in ~/.oakridge/journal")
+	fs.BoolVar(&o.Resume, "resume", false, "carry on with unfinished devices of the last run, or of --journal, after the last stage each finished")
+	fs.DurationVar(&o.Verify_wait, "verify-timeout", driver.Verify_timeout, "wait at most `duration` for a flashed device to come back on the new firmware, 0 skips the check")
+}
+
+// Setup applies options, call once flags are parsed
+func (o *Options) Setup() error {
+	if err := set_output(o.Output); err != nil {
+		return err
+	}
+	if o.Concurrency < 1 || o.Rate < 0 {
+		return fmt.Errorf("concurrency must be at least 1 and rate not negative")
+	}
+	Scan_concurrency, Scan_rate = o.Concurrency, o.Rate
+	if o.Ports != "" {
+		ports, err := parse_ports(o.Ports)
+		if err != nil {
+			return err
+		}
+		Scan_ports = ports
+	}
+	if o.Probe > 0 {
+		Probe_timeout = o.Probe
+	}
+	Ubnt_discovery, Ubnt_unicast = !o.No_discovery, o.Unicast
+	Arp_candidates, Ping_sweep = o.Arp, o.Ping
+	if o.Yes && o.Select == "" {
+		o.Select = "all"
+	}
+	if _, err := driver.Parse_selector(o.Select); err != nil {
+		return err
+	}
+	cfg, err := oakUtility.Load_config(o.Config_file)
+	if err != nil {
+		return err
+	}
+	if err := oakUtility.Configure_image_servers(o.Image_server, o.Mirrors, cfg); err != nil {
+		return err
+	}
+	creds, err := oakUtility.Load_credentials(oakUtility.Credentials_file(o.Credentials, cfg))
+	if err != nil {
+		return err
+	}
+	driver.Set_credentials(creds)
+	if err := oakUtility.Use_known_hosts(o.Known_hosts, o.Host_key); err != nil {
+		return err
+	}
+	driver.Rotate_host_keys = o.Rotate_keys
+	if o.Verify_wait >= 0 {
+		driver.Verify_timeout = o.Verify_wait
+	}
+	if err := driver.Load_catalog(o.Catalog_file); err != nil {
+		return err
+	}
+	oakUtility.Verify_images = !o.No_verify
+	oakUtility.Sftp_fallback = !o.No_sftp
+	if o.Bundle_file != "" {
+		if err := oakUtility.Use_bundle(o.Bundle_file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parse_ports(list string) ([]string, error) {
+	var ports []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("bad port %q", p)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+func Cleanup() {
+	// latest version is cached per model family, always fetch fresh ones
+	files, _ := filepath.Glob("latest-swversion-*.txt")
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
+
+func Prepare_sshconf() {
+	if runtime.GOOS != "windows" {
+		exec.Command("mkdir -p ~/.ssh;[ -z \"$(sed -n '/TCPKeepAlive.*yes/p' ~/.ssh/config 2>/dev/null)\" ] && sed -i '1 iTCPKeepAlive yes' ~/.ssh/config 2>/dev/null")
+	}
+}
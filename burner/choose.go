@@ -0,0 +1,215 @@
+package burner
+
+import (
+	"bufio"
+	"fmt"
+	"image_burner/driver"
+	"image_burner/spinner"
+	"image_burner/util"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Is_target tells if op makes sense for d, upgrade only when behind latest
+func Is_target(d *driver.Device, op driver.Operation) bool {
+	// found by discovery only, no way in yet
+	if d.User == "" {
+		return false
+	}
+	if !d.Driver.Support(d, op) {
+		return false
+	}
+	return op != driver.OP_UPGRADE || d.Firmware != d.LatestFW
+}
+
+func Targets(devs []*driver.Device, op driver.Operation) []*driver.Device {
+	var targets []*driver.Device
+	for _, d := range devs {
+		if Is_target(d, op) {
+			targets = append(targets, d)
+		}
+	}
+	return targets
+}
+
+func Oakdev_PrintHeader() {
+	fmt.Printf("\n%-4s %-12s%-16s%-18s%-16s%-25s%s\n", "No.", "SW", "HW", "Mac", "IPv4", "Description", "Latest-OakFirmware")
+	fmt.Printf("%s\n", strings.Repeat("=", 116))
+}
+
+// List_devices prints scan result, devices op can work on are ticked
+func List_devices(devs []*driver.Device, op driver.Operation) {
+	Oakdev_PrintHeader()
+	for i, d := range devs {
+		if op != 0 && Is_target(d, op) {
+			fmt.Printf("✓%-3d %s\n", i+1, d.OneLineSummary())
+		} else {
+			fmt.Printf(" %-3d %s\n", i+1, d.OneLineSummary())
+		}
+	}
+}
+
+// devices matching --select instead of asking, nil if nothing to do
+func selected_targets(targets []*driver.Device, verb string, o *Options) []*driver.Device {
+	picked, _ := driver.Select(targets, o.Select) // checked in Setup
+	if len(picked) == 0 {
+		fmt.Printf("\nNo device matches %q to %s\n", o.Select, verb)
+		return nil
+	}
+	fmt.Printf("\nTo %s %d devices:\n", verb, len(picked))
+	for _, d := range picked {
+		fmt.Printf("  %-16s %-18s %s\n", d.IPv4, d.Mac, d.Name)
+	}
+	if !o.Yes && !oakUtility.Confirm("Continue?") {
+		return nil
+	}
+	return picked
+}
+
+// Choose_targets asks which targets to work on, or takes them from --select
+func Choose_targets(targets []*driver.Device, verb string, o *Options) []*driver.Device {
+	if len(targets) == 0 {
+		fmt.Printf("\nNo device to %s\n", verb)
+		return nil
+	}
+	if o.Select != "" {
+		return selected_targets(targets, verb, o)
+	}
+
+	var choice int
+	for {
+		fmt.Printf("\nChoose which device to %s(ctrl-C to exist):\n", verb)
+		if len(targets) > 1 {
+			println("[0]. All devices")
+		}
+		for i, d := range targets {
+			fmt.Printf("[%d]. %-16s %-18s %s %s %s\n", i+1, d.IPv4, d.Mac, d.Name, d.Firmware, d.LatestFW)
+		}
+
+		if len(targets) > 1 {
+			fmt.Printf("Please choose: [0~%d]\n", len(targets))
+		} else {
+			fmt.Printf("Please choose: [%d]\n", len(targets))
+		}
+		r := bufio.NewReader(os.Stdin)
+		input, err := r.ReadString('\n')
+		if err != nil {
+			println(err.Error())
+			continue
+		}
+
+		if choice, err = strconv.Atoi(strings.TrimSpace(input)); err != nil {
+			println(err.Error())
+			continue
+		}
+
+		if choice >= 0 && choice <= len(targets) {
+			oakUtility.ClearLine()
+			fmt.Printf("You choose: %d\n", choice)
+			break
+		}
+
+		fmt.Printf("Invalid choicse: %d\n", choice)
+	}
+
+	if choice == 0 {
+		return targets
+	}
+	return targets[choice-1 : choice]
+}
+
+// Choose_operation asks what to do with devs if more than one operation has
+// targets, 0 if none has
+func Choose_operation(devs []*driver.Device) driver.Operation {
+	var ops []driver.Operation
+	for _, op := range []driver.Operation{driver.OP_CONVERT, driver.OP_UPGRADE, driver.OP_RESTORE} {
+		if len(Targets(devs, op)) > 0 {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) <= 1 {
+		if len(ops) == 0 {
+			println("\nNo device to convert, upgrade or restore")
+			return 0
+		}
+		return ops[0]
+	}
+
+	titles := map[driver.Operation]string{
+		driver.OP_CONVERT: "Convert Vendor Devices to OakFirmware",
+		driver.OP_UPGRADE: "Upgrade Oak Devices to Latest OakFirmware",
+		driver.OP_RESTORE: "Restore Oak Devices to Vendor Firmware",
+	}
+	r := bufio.NewReader(os.Stdin)
+	for {
+		println("\nChoose what do you want to do(ctrl-C to exist):")
+		for i, op := range ops {
+			fmt.Printf("[%d]. %s\n", i+1, titles[op])
+		}
+		fmt.Printf("Please choose: [1~%d]\n", len(ops))
+		input, err := r.ReadString('\n')
+		if err != nil {
+			println(err.Error())
+			if input == "" {
+				return 0
+			}
+		}
+
+		choice, err := strconv.Atoi(strings.TrimSpace(input))
+		if err != nil {
+			println(err.Error())
+			continue
+		}
+
+		if choice > 0 && choice <= len(ops) {
+			oakUtility.ClearLine()
+			fmt.Printf("You choose: %d\n", choice)
+			return ops[choice-1]
+		}
+
+		fmt.Printf("Invalid choicse: %d\n", choice)
+	}
+}
+
+// Run_all runs op on all targets in parallel, returns how many failed
+func Run_all(targets []*driver.Device, op driver.Operation, one func(*driver.Device) error) int {
+	// several spinners on one line garble each other, give each device a row
+	var board *spinner.Board
+	if len(targets) > 1 {
+		board = spinner.NewBoard().Start()
+		oakUtility.Download_progress = false
+		defer func() {
+			board.Stop()
+			oakUtility.Download_progress = true
+		}()
+	}
+
+	var s sync.WaitGroup
+	var failed int32
+	for _, t := range targets {
+		if board != nil {
+			t.Row = board.Add(t.IPv4 + " " + t.HWmodel)
+		}
+		s.Add(1)
+		go func(d *driver.Device) {
+			defer s.Done()
+			started := time.Now()
+			journal_record(d, "running", nil)
+			err := one(d)
+			if err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+			if d.Row != nil {
+				d.Row.Done(err)
+			}
+			journal_record(d, job_status(err), err)
+			report_job(d, op, started, err)
+		}(t)
+	}
+	s.Wait()
+	return int(failed)
+}
@@ -0,0 +1,112 @@
+package burner
+
+import (
+	"fmt"
+	"image_burner/driver"
+	"image_burner/util"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Choose_models parses a comma separated model list, "all" is the whole catalog
+func Choose_models(list string) ([]*driver.Model, error) {
+	var models []*driver.Model
+	if list == "" || list == "all" {
+		c := driver.Get_catalog()
+		for i := range c.Models {
+			models = append(models, &c.Models[i])
+		}
+		return models, nil
+	}
+	for _, id := range strings.Split(list, ",") {
+		m := driver.Find_model(strings.TrimSpace(id))
+		if m == nil {
+			return nil, fmt.Errorf("unknown model %s", id)
+		}
+		models = append(models, m)
+	}
+	return models, nil
+}
+
+// Fetch_bundle downloads everything models need, returns what goes into the bundle
+func Fetch_bundle(models []*driver.Model, tmpdir string) ([]oakUtility.Bundle_entry, error) {
+	var entries []oakUtility.Bundle_entry
+	seen := map[string]bool{}
+
+	// small text files are always fetched fresh
+	fetch_txt := func(url string) error {
+		if seen[url] {
+			return nil
+		}
+		seen[url] = true
+		localfile := filepath.Join(tmpdir, fmt.Sprintf("%d.txt", len(seen)))
+		if err := oakUtility.Download_from_servers(localfile, url, false); err != nil {
+			return err
+		}
+		entries = append(entries, oakUtility.Bundle_entry{URL: url, Localfile: localfile})
+		return nil
+	}
+
+	families := map[string]bool{}
+	for _, m := range models {
+		for _, img := range m.Images() {
+			if seen[img.URL] {
+				continue
+			}
+			seen[img.URL] = true
+			if err := oakUtility.Download_image(img.File, img.URL); err != nil {
+				return nil, err
+			}
+			entries = append(entries, oakUtility.Bundle_entry{URL: img.URL, Localfile: img.File})
+
+			if oakUtility.Verify_images {
+				if err := fetch_txt(oakUtility.Manifest_url(img.URL)); err != nil {
+					return nil, err
+				}
+				if oakUtility.Signing_key != nil {
+					if err := fetch_txt(oakUtility.Signature_url(img.URL)); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		families[m.Family] = true
+	}
+
+	for fam := range families {
+		f, ok := driver.Get_catalog().Families[fam]
+		if !ok || f.Latest_version == "" {
+			continue
+		}
+		if err := fetch_txt(f.Latest_version); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Verify_images checks downloaded images of models, returns how many are bad
+func Verify_images(models []*driver.Model) int {
+	bad := 0
+	seen := map[string]bool{}
+	for _, m := range models {
+		for _, img := range m.Images() {
+			if seen[img.File] {
+				continue
+			}
+			seen[img.File] = true
+			if _, err := os.Stat(img.File); err != nil {
+				fmt.Printf("  %-10s %s\n", "missing", img.File)
+				continue
+			}
+			if err := oakUtility.Verify_image(img.File, img.URL); err != nil {
+				fmt.Printf("  %-10s %s\n", "BAD", err.Error())
+				bad++
+				continue
+			}
+			fmt.Printf("  %-10s %s\n", "ok", img.File)
+		}
+	}
+	return bad
+}
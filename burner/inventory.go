@@ -0,0 +1,31 @@
+package burner
+
+import (
+	"image_burner/driver"
+	"sync"
+)
+
+// Inventory collects devices from concurrent probes and jobs
+type Inventory struct {
+	lock sync.Mutex
+	devs []*driver.Device
+}
+
+func (inv *Inventory) Add(d *driver.Device) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	inv.devs = append(inv.devs, d)
+}
+
+// List returns a copy, safe to use while others still Add
+func (inv *Inventory) List() []*driver.Device {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return append([]*driver.Device(nil), inv.devs...)
+}
+
+func (inv *Inventory) Len() int {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return len(inv.devs)
+}
@@ -0,0 +1,128 @@
+package burner
+
+import (
+	"fmt"
+	"image_burner/driver"
+	"image_burner/util"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run with -race, probes add while the report side reads
+func TestInventoryConcurrent(t *testing.T) {
+	const writers, per_writer = 32, 100
+	var inv Inventory
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, d := range inv.List() {
+					_ = d.IPv4
+				}
+				if n := inv.Len(); n > writers*per_writer {
+					t.Errorf("Len %d over what was added", n)
+				}
+			}
+		}()
+	}
+
+	var adders sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		adders.Add(1)
+		go func(w int) {
+			defer adders.Done()
+			for i := 0; i < per_writer; i++ {
+				inv.Add(&driver.Device{IPv4: fmt.Sprintf("10.%d.%d.1", w, i)})
+			}
+		}(w)
+	}
+	adders.Wait()
+	close(stop)
+	wg.Wait()
+
+	if n := inv.Len(); n != writers*per_writer {
+		t.Fatalf("Len %d, want %d", n, writers*per_writer)
+	}
+	seen := map[string]bool{}
+	for _, d := range inv.List() {
+		if seen[d.IPv4] {
+			t.Fatalf("%s listed twice", d.IPv4)
+		}
+		seen[d.IPv4] = true
+	}
+}
+
+// a port nothing listens on, probes to it fail at once
+func closed_port(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	return port
+}
+
+// scan_one from the pool, found devices added next to it and discovery
+// merged while they run, the way Scan fills one subnet
+func TestSubnetScanConcurrent(t *testing.T) {
+	saved_ports, saved_timeout := Scan_ports, Probe_timeout
+	defer func() { Scan_ports, Probe_timeout = saved_ports, saved_timeout }()
+	Scan_ports = []string{closed_port(t)}
+	Probe_timeout = 200 * time.Millisecond
+
+	s := New_Subnet("127.0.0.0/24", nil)
+	pool := oakUtility.New_Pool(32, 0)
+	defer pool.Close()
+	for i := 1; i < 255; i++ {
+		host := fmt.Sprintf("127.0.0.%d", i)
+		pool.Submit(func() { s.scan_one(host) })
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Devs.Add(&driver.Device{IPv4: fmt.Sprintf("127.0.0.%d", i), Mac: fmt.Sprintf("02:00:00:00:00:%02x", i)})
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.merge_ubnt(map[string]*oakUtility.Ubnt_reply{
+			"02:00:00:00:01:00": {Mac: "02:00:00:00:01:00", IPv4: "127.0.0.200", Systemid: "ffff"},
+			"02:00:00:00:02:00": {Mac: "02:00:00:00:02:00", IPv4: "192.168.9.9", Systemid: "ffff"},
+		})
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				s.Devs.List()
+				s.Devs.Len()
+			}
+		}()
+	}
+	pool.Wait()
+	wg.Wait()
+
+	if n := s.Devs.Len(); n != 21 {
+		t.Fatalf("%d devices, want 20 added and 1 discovered", n)
+	}
+	if len(s.foreign) != 1 || s.foreign[0].IPv4 != "192.168.9.9" {
+		t.Fatalf("foreign replies %v, want the one outside %s", s.foreign, s.Net)
+	}
+}
@@ -0,0 +1,306 @@
+package burner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image_burner/driver"
+	"image_burner/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * every run which changes devices journals its jobs, one json line per
+ * change keyed by mac, the last line of a mac is where it stands. stages a
+ * device can not go back from are recorded as they finish, so --resume can
+ * carry on after the last one once a run was killed or the laptop slept
+ */
+type Journal_record struct {
+	Time            time.Time `json:"time"`
+	Operation       string    `json:"operation"`
+	Mac             string    `json:"mac"`
+	IPv4            string    `json:"ipv4"`
+	Port            string    `json:"port,omitempty"`
+	Vendor          string    `json:"vendor"`
+	Model           string    `json:"model"`
+	Name            string    `json:"name"`
+	Driver          string    `json:"driver"`
+	Firmware        string    `json:"firmware"` // before the job
+	Latest_firmware string    `json:"latest_firmware,omitempty"`
+	Stage           string    `json:"stage"`  // last stage done, "" none yet
+	Status          string    `json:"status"` // "running", "ok", "failed" or "timeout"
+	Error           string    `json:"error,omitempty"`
+}
+
+type Journal struct {
+	file string
+	op   driver.Operation
+	lock sync.Mutex
+	last map[string]Journal_record // by mac
+	macs []string                  // in order first seen
+	torn bool                      // last line cut short, next record starts a new one
+}
+
+var journal *Journal
+
+func Journal_dir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "journal"
+	}
+	return filepath.Join(home, ".oakridge", "journal")
+}
+
+func new_journal_file(op driver.Operation) string {
+	return filepath.Join(Journal_dir(), op.String()+"-"+time.Now().Format("20060102-150405")+".jsonl")
+}
+
+// journal of the last run of op, names sort by time
+func latest_journal_file(op driver.Operation) (string, error) {
+	files, _ := filepath.Glob(filepath.Join(Journal_dir(), op.String()+"-*.jsonl"))
+	if len(files) == 0 {
+		return "", fmt.Errorf("no %s journal in %s to resume", op, Journal_dir())
+	}
+	sort.Strings(files)
+	return files[len(files)-1], nil
+}
+
+func journal_key(mac string) string {
+	return strings.ToLower(mac)
+}
+
+// Open_journal reads records of op in file if it exists, new ones are appended
+func Open_journal(file string, op driver.Operation) (*Journal, error) {
+	j := &Journal{file: file, op: op, last: map[string]Journal_record{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.torn = len(data) > 0 && data[len(data)-1] != '\n'
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r Journal_record
+		if err := json.Unmarshal(line, &r); err != nil {
+			// a line cut short when the run was killed
+			log.Debug.Printf("%s:%d: %s\n", file, n+1, err.Error())
+			continue
+		}
+		if r.Operation != op.String() || r.Mac == "" {
+			continue
+		}
+		j.add(r)
+	}
+	return j, nil
+}
+
+// called with lock held
+func (j *Journal) add(r Journal_record) {
+	key := journal_key(r.Mac)
+	if _, ok := j.last[key]; !ok {
+		j.macs = append(j.macs, key)
+	}
+	j.last[key] = r
+}
+
+// Record appends where d stands, status is "running" while the job goes on
+func (j *Journal) Record(d *driver.Device, status string, err error) error {
+	if d.Mac == "" {
+		return nil
+	}
+	r := Journal_record{
+		Time:            time.Now(),
+		Operation:       j.op.String(),
+		Mac:             d.Mac,
+		IPv4:            d.IPv4,
+		Port:            d.Port,
+		Vendor:          d.Vendor,
+		Model:           d.HWmodel,
+		Name:            d.Name,
+		Firmware:        d.Firmware,
+		Latest_firmware: d.LatestFW,
+		Stage:           d.Stage,
+		Status:          status,
+	}
+	if d.Driver != nil {
+		r.Driver = d.Driver.Name()
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if old, ok := j.last[journal_key(d.Mac)]; ok && status != "running" {
+		// verification moved d.Firmware on, keep what it had before the job
+		r.Firmware = old.Firmware
+	}
+	j.add(r)
+
+	line, e := json.Marshal(r)
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(filepath.Dir(j.file), 0700); e != nil {
+		return e
+	}
+	f, e := os.OpenFile(j.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if e != nil {
+		return e
+	}
+	if j.torn {
+		line = append([]byte("\n"), line...)
+	}
+	if _, e = f.Write(append(line, '\n')); e == nil {
+		j.torn = false
+		e = f.Sync()
+	}
+	if ce := f.Close(); e == nil {
+		e = ce
+	}
+	return e
+}
+
+// Unfinished are the last records of devices whose job did not end ok
+func (j *Journal) Unfinished() []Journal_record {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	var recs []Journal_record
+	for _, key := range j.macs {
+		if r := j.last[key]; r.Status != "ok" {
+			recs = append(recs, r)
+		}
+	}
+	return recs
+}
+
+// journal a job, errors only cost the resume, never the job
+func journal_record(d *driver.Device, status string, err error) {
+	if journal == nil {
+		return
+	}
+	if e := journal.Record(d, status, err); e != nil {
+		log.Error.Printf("journal %s: %s\n", journal.file, e.Error())
+	}
+}
+
+func use_journal(file string, op driver.Operation) error {
+	j, err := Open_journal(file, op)
+	if err != nil {
+		return err
+	}
+	journal = j
+	driver.On_stage = func(d *driver.Device) {
+		journal_record(d, "running", nil)
+	}
+	return nil
+}
+
+// device to carry on with the job of r, nil if it has to be skipped
+func resume_device(r Journal_record, devs []*driver.Device, op driver.Operation) *driver.Device {
+	var found *driver.Device
+	for _, d := range devs {
+		if journal_key(d.Mac) == journal_key(r.Mac) {
+			found = d
+			break
+		}
+	}
+	if r.Stage == "" {
+		// nothing done yet, start over if it still is a target
+		if found == nil || !Is_target(found, op) {
+			fmt.Printf("%s %s: not found as a %s target, skipped\n", r.Mac, r.IPv4, op)
+			return nil
+		}
+		return found
+	}
+	// half way the device runs some other image, the journal knows what it was
+	drv := driver.Lookup(r.Driver)
+	if drv == nil {
+		fmt.Printf("%s %s: unknown driver %q, skipped\n", r.Mac, r.IPv4, r.Driver)
+		return nil
+	}
+	d := &driver.Device{
+		Driver:   drv,
+		Vendor:   r.Vendor,
+		HWmodel:  r.Model,
+		Name:     r.Name,
+		Mac:      r.Mac,
+		IPv4:     r.IPv4,
+		Port:     r.Port,
+		Firmware: r.Firmware,
+		LatestFW: r.Latest_firmware,
+		Stage:    r.Stage,
+	}
+	if found != nil {
+		d.IPv4, d.Port = found.IPv4, found.Port
+	}
+	return d
+}
+
+// unfinished devices of the journal, after asking unless --yes
+func resume_targets(devs []*driver.Device, op driver.Operation, verb string, o *Options) []*driver.Device {
+	var targets []*driver.Device
+	for _, r := range journal.Unfinished() {
+		if d := resume_device(r, devs, op); d != nil {
+			targets = append(targets, d)
+		}
+	}
+	if o.Select != "" {
+		targets, _ = driver.Select(targets, o.Select) // checked in Setup
+	}
+	if len(targets) == 0 {
+		fmt.Printf("\nNothing to resume in %s\n", journal.file)
+		return nil
+	}
+	fmt.Printf("\nTo resume %s of %d devices from %s:\n", verb, len(targets), journal.file)
+	for _, d := range targets {
+		stage := d.Stage
+		if stage == "" {
+			stage = "start"
+		}
+		fmt.Printf("  %-16s %-18s %-16s after %s\n", d.IPv4, d.Mac, d.Name, stage)
+	}
+	if !o.Yes && !oakUtility.Confirm("Continue?") {
+		return nil
+	}
+	return targets
+}
+
+// Job_targets opens the journal of this run and picks the devices to run op
+// on, the unfinished ones of the last run with --resume
+func Job_targets(devs []*driver.Device, op driver.Operation, verb string, o *Options) []*driver.Device {
+	if o.Resume {
+		file := o.Journal
+		if file == "" {
+			var err error
+			if file, err = latest_journal_file(op); err != nil {
+				log.Error.Println(err)
+				return nil
+			}
+		}
+		if err := use_journal(file, op); err != nil {
+			log.Error.Println(err)
+			return nil
+		}
+		return resume_targets(devs, op, verb, o)
+	}
+
+	file := o.Journal
+	if file == "" {
+		file = new_journal_file(op)
+	}
+	if err := use_journal(file, op); err != nil {
+		log.Error.Printf("no journal, this run can not be resumed: %s\n", err.Error())
+	}
+	return Choose_targets(Targets(devs, op), verb, o)
+}
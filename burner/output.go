@@ -0,0 +1,184 @@
+package burner
+
+import (
+	"encoding/json"
+	"fmt"
+	"image_burner/driver"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+ * --output json|ndjson turns scan results and job outcomes into records for
+ * provisioning pipelines. ndjson writes one record per line as it happens,
+ * json writes one document when the command ends. Human text goes to stderr
+ * then, so stdout carries records only.
+ */
+const (
+	OUTPUT_TEXT   = "text"
+	OUTPUT_JSON   = "json"
+	OUTPUT_NDJSON = "ndjson"
+)
+
+type Device_record struct {
+	Type            string   `json:"type"` // "device"
+	Vendor          string   `json:"vendor"`
+	Model           string   `json:"model"`
+	Name            string   `json:"name"`
+	Mac             string   `json:"mac"`
+	IPv4            string   `json:"ipv4"`
+	Firmware        string   `json:"firmware"`
+	Latest_firmware string   `json:"latest_firmware,omitempty"`
+	Driver          string   `json:"driver"`
+	Actions         []string `json:"actions"` // operations the device is eligible for
+}
+
+type Job_record struct {
+	Type        string    `json:"type"` // "job"
+	Operation   string    `json:"operation"`
+	Mac         string    `json:"mac"`
+	IPv4        string    `json:"ipv4"`
+	Model       string    `json:"model"`
+	Status      string    `json:"status"` // "ok", "failed" or "timeout"
+	Error       string    `json:"error,omitempty"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	Duration_ms int64     `json:"duration_ms"`
+}
+
+var output_mode = OUTPUT_TEXT
+var output io.Writer = os.Stdout
+var output_lock sync.Mutex
+var devices_out []Device_record
+var jobs_out []Job_record
+
+func set_output(mode string) error {
+	switch mode {
+	case "", OUTPUT_TEXT:
+		output_mode = OUTPUT_TEXT
+		return nil
+	case OUTPUT_JSON, OUTPUT_NDJSON:
+		output_mode = mode
+	default:
+		return fmt.Errorf("output %s not supported, want text|json|ndjson", mode)
+	}
+	// keep stdout for records, everything else printed goes to stderr
+	output = os.Stdout
+	os.Stdout = os.Stderr
+	return nil
+}
+
+func Machine_output() bool {
+	return output_mode != OUTPUT_TEXT
+}
+
+func emit(v interface{}) {
+	output_lock.Lock()
+	defer output_lock.Unlock()
+
+	switch r := v.(type) {
+	case Device_record:
+		devices_out = append(devices_out, r)
+	case Job_record:
+		jobs_out = append(jobs_out, r)
+	}
+	if output_mode == OUTPUT_NDJSON {
+		json.NewEncoder(output).Encode(v)
+	}
+}
+
+func device_record(d *driver.Device) Device_record {
+	r := Device_record{
+		Type:            "device",
+		Vendor:          d.Vendor,
+		Model:           d.HWmodel,
+		Name:            d.Name,
+		Mac:             d.Mac,
+		IPv4:            d.IPv4,
+		Firmware:        d.Firmware,
+		Latest_firmware: d.LatestFW,
+		Driver:          d.Driver.Name(),
+		Actions:         []string{},
+	}
+	for _, op := range []driver.Operation{driver.OP_CONVERT, driver.OP_UPGRADE, driver.OP_RESTORE} {
+		if Is_target(d, op) {
+			r.Actions = append(r.Actions, op.String())
+		}
+	}
+	return r
+}
+
+// Report_devices lists scan result as table or records
+func Report_devices(devs []*driver.Device, op driver.Operation) {
+	if !Machine_output() {
+		List_devices(devs, op)
+		return
+	}
+	for _, d := range devs {
+		emit(device_record(d))
+	}
+}
+
+func report_job(d *driver.Device, op driver.Operation, started time.Time, err error) {
+	// the board row already shows the error
+	if err != nil && d.Row == nil {
+		log.Error.Printf("%s %s: %s\n", op, d.IPv4, err.Error())
+	}
+	if !Machine_output() {
+		if err == nil && d.Row == nil {
+			fmt.Printf("%s %s %s: ok\n", op, d.IPv4, d.HWmodel)
+		}
+		return
+	}
+	r := Job_record{
+		Type:        "job",
+		Operation:   op.String(),
+		Mac:         d.Mac,
+		IPv4:        d.IPv4,
+		Model:       d.HWmodel,
+		Status:      job_status(err),
+		Started:     started,
+		Finished:    time.Now(),
+		Duration_ms: time.Since(started).Nanoseconds() / int64(time.Millisecond),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	emit(r)
+}
+
+// "ok", "failed" or "timeout"
+func job_status(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case driver.Timed_out(err):
+		return "timeout"
+	}
+	return "failed"
+}
+
+// Flush_output writes the json document, call once before exit
+func Flush_output() {
+	if output_mode != OUTPUT_JSON {
+		return
+	}
+	output_lock.Lock()
+	defer output_lock.Unlock()
+
+	doc := struct {
+		Devices []Device_record `json:"devices"`
+		Jobs    []Job_record    `json:"jobs"`
+	}{devices_out, jobs_out}
+	if doc.Devices == nil {
+		doc.Devices = []Device_record{}
+	}
+	if doc.Jobs == nil {
+		doc.Jobs = []Job_record{}
+	}
+	enc := json.NewEncoder(output)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+}
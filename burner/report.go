@@ -0,0 +1,65 @@
+package burner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image_burner/driver"
+	"os"
+	"time"
+)
+
+// Write_OakAP_csv saves scanned Oakridge AP and newly converted AP for easy import to oakmgr
+func Write_OakAP_csv(devs []*driver.Device, converted []string) {
+	var maclist []string
+	for _, ap := range devs {
+		if !Is_oakridge(ap) || !ap.Is_ap() {
+			continue
+		}
+		maclist = append(maclist, ap.Mac)
+	}
+	maclist = append(maclist, converted...)
+
+	if len(maclist) == 0 {
+		return
+	}
+
+	const file string = "oakridge_ap.csv"
+	const tablehead string = "MAC"
+
+	f, err := os.Create(file)
+	if err != nil {
+		log.Error.Printf("%s\n", err.Error())
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	w.WriteString("# Automatically generated at " + time.Now().Format(time.RFC3339) + "\n")
+	w.WriteString(tablehead + "\n")
+
+	for _, m := range maclist {
+		w.WriteString(m + "\n")
+	}
+
+	w.Flush()
+
+	fmt.Printf("\nAll Oakridge AP saved in %s to be import into management system\n", file)
+}
+
+// Write_inventory saves every scanned device, whatever it runs
+func Write_inventory(file string, devs []*driver.Device) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"IPv4", "MAC", "Vendor", "HWmodel", "Name", "Firmware", "LatestFW", "Driver"})
+	for _, d := range devs {
+		w.Write([]string{d.IPv4, d.Mac, d.Vendor, d.HWmodel, d.Name, d.Firmware, d.LatestFW, d.Driver.Name()})
+	}
+	w.Flush()
+	return w.Error()
+}
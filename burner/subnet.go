@@ -0,0 +1,242 @@
+package burner
+
+import (
+	"fmt"
+	"image_burner/driver"
+	"image_burner/spinner"
+	"image_burner/util"
+	"net"
+	"time"
+)
+
+type Subnet struct {
+	Net     string
+	holes   []net.IP // skip those ip-addr
+	drivers []driver.Driver
+	Devs    Inventory
+	foreign []*oakUtility.Ubnt_reply // discovery replies from outside Net
+
+	arp                    bool // hosts came from the arp table
+	arp_hosts, arp_unknown int  // probed, and how many of them have an unknown mac vendor
+}
+
+var Scan_concurrency = 256 // hosts probed at the same time
+var Scan_rate = 0          // new hosts per second in a subnet, 0 no limit
+var Scan_ports = []string{"22"}
+var Probe_timeout = time.Second // tcp connect before ssh
+var Ubnt_discovery = true
+var Ubnt_unicast = false                // discovery to every host too, paced with the ssh probes
+var Discovery_timeout = 2 * time.Second // after the last discovery probe
+
+func New_Subnet(cidr string, drivers []driver.Driver) *Subnet {
+	return &Subnet{Net: cidr, drivers: drivers}
+}
+func (s *Subnet) Holes(h []net.IP) {
+	s.holes = h
+}
+
+func (s *Subnet) Scan() {
+	log.Info.Printf("scanning %s\n", s.Net)
+	hosts, err := oakUtility.Net2hosts_exclude(s.Net, s.holes)
+	if err != nil {
+		fmt.Println(s.Net, err)
+		return
+	}
+
+	// spinner
+	p := spinner.StartNew(s.Net)
+	defer func() {
+		p.Stop()
+	}()
+
+	var hints map[string]oakUtility.Neighbor
+	if Arp_candidates {
+		if Ping_sweep {
+			ping_sweep(hosts)
+		}
+		hosts, hints, s.arp_unknown = arp_candidates(hosts)
+		s.arp, s.arp_hosts = true, len(hosts)
+	}
+
+	// ubnt discovery replies are collected while the ssh sweep runs
+	var disc *oakUtility.Ubnt_discovery
+	if Ubnt_discovery {
+		if disc, err = oakUtility.Open_ubnt_discovery(); err != nil {
+			log.Debug.Printf("%s ubnt discovery: %s\n", s.Net, err.Error())
+		} else {
+			disc.Probe("255.255.255.255")
+			if b := oakUtility.Broadcast_addr(s.Net); b != "" {
+				disc.Probe(b)
+			}
+		}
+	}
+
+	// hosts in one subnet go in parallel, bounded so a /16 does not open 65k dials
+	pool := oakUtility.New_Pool(Scan_concurrency, Scan_rate)
+	defer pool.Close()
+	for _, h := range hosts {
+		host := h
+		pool.Submit(func() {
+			if disc != nil && Ubnt_unicast {
+				disc.Probe(host)
+			}
+			s.scan_one(host)
+		})
+	}
+	pool.Wait()
+
+	if disc != nil {
+		ubnt, err := disc.Collect(Discovery_timeout)
+		if err != nil {
+			log.Debug.Printf("%s ubnt discovery: %s\n", s.Net, err.Error())
+		}
+		s.merge_ubnt(ubnt)
+	}
+	s.merge_hints(hints)
+}
+
+// discovery fills what ssh did not find out, and adds devices ssh could not log in
+func (s *Subnet) merge_ubnt(replies map[string]*oakUtility.Ubnt_reply) {
+	_, ipnet, _ := net.ParseCIDR(s.Net)
+	devs := s.Devs.List()
+	for _, r := range replies {
+		if ip := net.ParseIP(r.IPv4); ip == nil || ipnet == nil || !ipnet.Contains(ip) {
+			s.foreign = append(s.foreign, r)
+			continue
+		}
+		found := driver.From_ubnt_reply(r)
+		if found == nil {
+			continue
+		}
+		known := false
+		for _, d := range devs {
+			if d.IPv4 == r.IPv4 {
+				d.Fill(found)
+				known = true
+				break
+			}
+		}
+		if !known {
+			log.Info.Printf("%s found by ubnt discovery only\n", r.IPv4)
+			s.Devs.Add(found)
+		}
+	}
+}
+func (s *Subnet) scan_one(host string) {
+
+	port := oakUtility.Probe_port(host, Scan_ports, Probe_timeout)
+	if port == "" {
+		log.Debug.Printf("%s: no open port in %v\n", host, Scan_ports)
+		return
+	}
+
+	c := oakUtility.New_SSHClient(host)
+	c.Port = port
+
+	if dev := driver.Detect(&c, s.drivers); dev != nil {
+		log.Info.Printf("%s is %s device\n", c.IPv4, dev.Driver.Name())
+		s.Devs.Add(dev)
+	}
+}
+
+func (s *Subnet) OneLineSummary() {
+	devs := s.Devs.List()
+	oak_cnt := 0
+	for _, d := range devs {
+		if Is_oakridge(d) {
+			oak_cnt++
+		}
+	}
+	fmt.Printf("✓ %s: %d Oakridge, %d 3rd-party devices\n", s.Net, oak_cnt, len(devs)-oak_cnt)
+	if s.arp {
+		fmt.Printf("  %d hosts in arp table probed, %d of them of unknown mac vendor\n", s.arp_hosts, s.arp_unknown)
+	}
+}
+
+func Is_oakridge(d *driver.Device) bool {
+	return d.Driver.Name() == "oakridge"
+}
+
+// Scan_networks scans subnets/hosts in args, or all local subnets if none
+func Scan_networks(args []string, drivers []driver.Driver) ([]*Subnet, error) {
+	var nets []string
+	var selfs []net.IP
+
+	if len(args) > 0 {
+		for _, arg := range args {
+			n, err := oakUtility.String2netstring(arg)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+		println("Scan user input networks ...\n")
+	} else {
+		var err error
+		if nets, selfs, err = oakUtility.Get_local_subnets(); err != nil {
+			return nil, err
+		}
+		println("Scanning local networks ...\n")
+	}
+
+	// scan each subnet
+	var netlist []*Subnet
+	for _, n := range nets {
+		s := New_Subnet(n, drivers)
+		s.Holes(selfs)
+		s.Scan()
+		s.OneLineSummary()
+		netlist = append(netlist, s)
+	}
+
+	// ubnt devices answering broadcast from other ip subnets on the same wire
+	if d := discovered_elsewhere(netlist, drivers); d != nil {
+		d.OneLineSummary()
+		netlist = append(netlist, d)
+	}
+	return netlist, nil
+}
+
+func discovered_elsewhere(netlist []*Subnet, drivers []driver.Driver) *Subnet {
+	var ipnets []*net.IPNet
+	for _, s := range netlist {
+		if _, ipnet, err := net.ParseCIDR(s.Net); err == nil {
+			ipnets = append(ipnets, ipnet)
+		}
+	}
+	scanned := func(ip net.IP) bool {
+		for _, n := range ipnets {
+			if ip != nil && n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	d := New_Subnet("discovered", drivers)
+	seen := map[string]bool{}
+	for _, s := range netlist {
+		for _, r := range s.foreign {
+			if seen[r.Mac] || scanned(net.ParseIP(r.IPv4)) {
+				continue
+			}
+			seen[r.Mac] = true
+			if dev := driver.From_ubnt_reply(r); dev != nil {
+				d.Devs.Add(dev)
+			}
+		}
+	}
+	if d.Devs.Len() == 0 {
+		return nil
+	}
+	return d
+}
+
+// Devices flattens devices of all subnets
+func Devices(netlist []*Subnet) []*driver.Device {
+	var devs []*driver.Device
+	for _, n := range netlist {
+		devs = append(devs, n.Devs.List()...)
+	}
+	return devs
+}
@@ -0,0 +1,239 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"image_burner/util"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// newest catalog format this binary understands
+const CATALOG_VERSION = 1
+
+type Image struct {
+	Role       string `json:"role,omitempty"`
+	File       string `json:"file"` // local cache file name
+	URL        string `json:"url"`
+	Sysupgrade string `json:"sysupgrade,omitempty"` // file name inside the tarball
+}
+
+// Flash tells how one operation writes a model, Method is a key of flash_methods
+type Flash struct {
+	Method string  `json:"method"`
+	Images []Image `json:"images"`
+}
+
+func (f *Flash) Image(role string) *Image {
+	for i := range f.Images {
+		if f.Images[i].Role == role {
+			return &f.Images[i]
+		}
+	}
+	return nil
+}
+
+type Family struct {
+	Latest_version string `json:"latest_version"` // url of latest-swversion.txt, may be relative
+}
+
+type Model struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type,omitempty"`  // "ap" or "router", default "ap"
+	Driver  string   `json:"driver"`          // driver which handles factory firmware
+	Match   []string `json:"match,omitempty"` // what factory firmware reports for this model
+	Family  string   `json:"family"`
+	Convert *Flash   `json:"convert,omitempty"`
+	Upgrade *Flash   `json:"upgrade,omitempty"`
+	Restore *Flash   `json:"restore,omitempty"`
+}
+
+func (m *Model) Flash(op Operation) *Flash {
+	switch op {
+	case OP_CONVERT:
+		return m.Convert
+	case OP_UPGRADE:
+		return m.Upgrade
+	case OP_RESTORE:
+		return m.Restore
+	}
+	return nil
+}
+
+// every image any operation of this model may need
+func (m *Model) Images() []Image {
+	var imgs []Image
+	for _, f := range []*Flash{m.Convert, m.Upgrade, m.Restore} {
+		if f != nil {
+			imgs = append(imgs, f.Images...)
+		}
+	}
+	return imgs
+}
+
+type Catalog struct {
+	Version      int               `json:"version"`
+	Image_server string            `json:"image_server,omitempty"` // base of relative image urls
+	Signing_key  string            `json:"signing_key,omitempty"`  // base64 ed25519 key which signs SHA256SUMS
+	Families     map[string]Family `json:"families"`
+	Models       []Model           `json:"models"`
+	Oui          map[string]string `json:"oui,omitempty"` // mac prefix to vendor, on top of the built-in ones
+}
+
+var catalog *Catalog
+
+func Parse_catalog(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Version < 1 || c.Version > CATALOG_VERSION {
+		return nil, fmt.Errorf("catalog version %d not supported, need 1~%d", c.Version, CATALOG_VERSION)
+	}
+	// images are cached by file name, one name must always be the same image
+	urls := map[string]string{}
+	for _, m := range c.Models {
+		if m.ID == "" {
+			return nil, fmt.Errorf("catalog model without id")
+		}
+		for _, f := range []*Flash{m.Convert, m.Upgrade, m.Restore} {
+			if f == nil {
+				continue
+			}
+			if _, ok := flash_methods[f.Method]; !ok {
+				return nil, fmt.Errorf("model %s: unknown flash method %q", m.ID, f.Method)
+			}
+		}
+		for _, img := range m.Images() {
+			if url, ok := urls[img.File]; ok && url != img.URL {
+				return nil, fmt.Errorf("model %s: file %s is both %s and %s", m.ID, img.File, url, img.URL)
+			}
+			urls[img.File] = img.URL
+		}
+	}
+	if c.Signing_key != "" {
+		if _, err := oakUtility.Parse_signing_key(c.Signing_key); err != nil {
+			return nil, fmt.Errorf("signing_key: %s", err.Error())
+		}
+	}
+	return &c, nil
+}
+
+func use_catalog(c *Catalog) {
+	catalog = c
+	latest_lock.Lock()
+	latest_versions = map[string]*latest_entry{}
+	latest_lock.Unlock()
+	oakUtility.Set_catalog_server(c.Image_server)
+	oakUtility.Add_oui(c.Oui)
+	oakUtility.Signing_key = nil
+	if c.Signing_key != "" {
+		oakUtility.Signing_key, _ = oakUtility.Parse_signing_key(c.Signing_key)
+	}
+}
+
+// Load_catalog replaces the built-in catalog with the one in file, "" keeps the built-in one
+func Load_catalog(file string) error {
+	if file == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	c, err := Parse_catalog(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	use_catalog(c)
+	return nil
+}
+
+func Get_catalog() *Catalog {
+	return catalog
+}
+
+// Find_model looks up a model by id or alias
+func Find_model(id string) *Model {
+	for i, m := range catalog.Models {
+		if strings.EqualFold(m.ID, id) {
+			return &catalog.Models[i]
+		}
+		for _, a := range m.Aliases {
+			if strings.EqualFold(a, id) {
+				return &catalog.Models[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Match_model looks up which model factory firmware of driver is talking about
+func Match_model(driver string, reported string) *Model {
+	for i, m := range catalog.Models {
+		if m.Driver != driver {
+			continue
+		}
+		for _, r := range m.Match {
+			if r == reported {
+				return &catalog.Models[i]
+			}
+		}
+	}
+	return nil
+}
+
+type latest_entry struct {
+	once    sync.Once
+	version string
+}
+
+// fetched once per family and run, a failure too, so an offline site
+// does not wait for the image server again on every device
+var latest_versions = map[string]*latest_entry{}
+var latest_lock sync.Mutex
+
+// latest Oakridge firmware version of a family, "" on error
+func latest_version(family string) string {
+	latest_lock.Lock()
+	e, ok := latest_versions[family]
+	if !ok {
+		e = &latest_entry{}
+		latest_versions[family] = e
+	}
+	latest_lock.Unlock()
+	e.once.Do(func() {
+		e.version = fetch_latest_version(family)
+	})
+	return e.version
+}
+
+func fetch_latest_version(family string) string {
+	f, ok := catalog.Families[family]
+	if !ok || f.Latest_version == "" {
+		return ""
+	}
+	localfile := "latest-swversion-" + family + ".txt"
+	if err := oakUtility.On_demand_fetch(localfile, f.Latest_version); err != nil {
+		log.Error.Println(err.Error())
+		return ""
+	}
+
+	dat, err := ioutil.ReadFile(localfile)
+	if err != nil {
+		log.Error.Println(err.Error())
+		return ""
+	}
+	return strings.TrimSpace(string(dat))
+}
+
+func init() {
+	c, err := Parse_catalog([]byte(default_catalog))
+	if err != nil {
+		panic("built-in catalog: " + err.Error())
+	}
+	use_catalog(c)
+}
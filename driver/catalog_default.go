@@ -0,0 +1,202 @@
+package driver
+
+// built-in model catalog, used unless --catalog points to another file.
+// bump "version" only when the format changes, not for new models or images
+const default_catalog = `{
+  "version": 1,
+  "image_server": "http://image.oakridge.vip:8000/",
+  "families": {
+    "ap152": {"latest_version": "images/ap/ap152/sysloader/latest-swversion.txt"},
+    "ubntunifi": {"latest_version": "images/ap/ubntunifi/sysloader/latest-swversion.txt"},
+    "ubnterx": {"latest_version": "images/ap/ubnterx/sysloader/latest-swversion.txt"}
+  },
+  "models": [
+    {
+      "id": "AC-LITE",
+      "aliases": ["ubntlite"],
+      "name": "UBNT_AC-LITE",
+      "driver": "ubnt_ap",
+      "match": ["e517"],
+      "family": "ubntunifi",
+      "convert": {"method": "unifi-kernel", "images": [
+        {"file": "oakridge.sysloader.ubnt.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ubnt-unifi-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "aclite.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "aclite.origin.tar.gz", "url": "images/ap/ubntunifi/origin/AC-LITE/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "AC-LR",
+      "aliases": ["ubntlr"],
+      "name": "UBNT_AC-LR",
+      "driver": "ubnt_ap",
+      "match": ["e527"],
+      "family": "ubntunifi",
+      "convert": {"method": "unifi-kernel", "images": [
+        {"file": "oakridge.sysloader.ubnt.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ubnt-unifi-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "aclr.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "aclr.origin.tar.gz", "url": "images/ap/ubntunifi/origin/AC-LR/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "AC-PRO",
+      "aliases": ["ubntpro"],
+      "name": "UBNT_AC-PRO",
+      "driver": "ubnt_ap",
+      "match": ["e537"],
+      "family": "ubntunifi",
+      "convert": {"method": "unifi-kernel", "images": [
+        {"file": "oakridge.sysloader.ubnt.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ubnt-unifi-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "acpro.tar.gz", "url": "images/ap/ubntunifi/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "acpro.origin.tar.gz", "url": "images/ap/ubntunifi/origin/AC-PRO/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "A820",
+      "name": "QTS_A820",
+      "driver": "qts",
+      "match": ["A820"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.a820.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "a820.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "a820.origin.tar.gz", "url": "images/ap/ap152/origin/A820/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "A822",
+      "name": "QTS_A822",
+      "driver": "qts",
+      "match": ["A822"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.a822.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "a822.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "a822.origin.tar.gz", "url": "images/ap/ap152/origin/A822/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "A826",
+      "name": "QTS_A826",
+      "driver": "qts",
+      "match": ["A826"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.a826.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "a826.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "a826.origin.tar.gz", "url": "images/ap/ap152/origin/A826/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "W282",
+      "name": "QTS_W282",
+      "driver": "qts",
+      "match": ["W282"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.w282.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "w282.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "w282.origin.tar.gz", "url": "images/ap/ap152/origin/W282/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "A920",
+      "name": "QTS_A920",
+      "driver": "qts",
+      "match": ["A920"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.a920.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "a920.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "a920.origin.tar.gz", "url": "images/ap/ap152/origin/A920/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "A923",
+      "name": "DCN_SEAP-380",
+      "driver": "qts",
+      "match": ["A923"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.a923.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "a923.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "a923.origin.tar.gz", "url": "images/ap/ap152/origin/A923/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "WL8200-I2",
+      "name": "DCN_WL8200-I2",
+      "driver": "qts",
+      "match": ["WL8200-I2"],
+      "family": "ap152",
+      "convert": {"method": "sysupgrade", "images": [
+        {"file": "oakridge.wl8200_i2.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "openwrt-ar71xx-generic-ap152-16M-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "wl8200_i2.tar.gz", "url": "images/ap/ap152/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "mtd-firmware", "images": [
+        {"file": "wl8200_i2.origin.tar.gz", "url": "images/ap/ap152/origin/WL8200-I2/firmware.bin.tar.gz", "sysupgrade": "firmware.bin"}
+      ]}
+    },
+    {
+      "id": "EdgeRouter_ER-X",
+      "aliases": ["ubnterx", "UBNT_ERX"],
+      "name": "UBNT_EdgeRouter-X",
+      "type": "router",
+      "driver": "ubnt_erx",
+      "match": ["EdgeRouter X 5-Port", "ER-X"],
+      "family": "ubnterx",
+      "convert": {"method": "erx-factory", "images": [
+        {"role": "factory", "file": "erx_factory.bin.tar.gz", "url": "images/ap/ubnterx/origin/factory.bin.tar.gz", "sysupgrade": "lede-ramips-mt7621-ubnt-erx-initramfs-factory.tar"},
+        {"role": "oakridge", "file": "oakridge_sysupgrade.bin.tar.gz", "url": "images/ap/ubnterx/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "lede-ramips-mt7621-ubnt-erx-squashfs-sysupgrade.bin"}
+      ]},
+      "upgrade": {"method": "sysupgrade-oak", "images": [
+        {"file": "ubnterx.tar.gz", "url": "images/ap/ubnterx/sysloader/latest-sysupgrade.bin.tar.gz", "sysupgrade": "*-squashfs-sysupgrade.bin"}
+      ]},
+      "restore": {"method": "erx-recover", "images": [
+        {"role": "recover", "file": "erx_recover.tar.tar.gz", "url": "images/ap/ubnterx/origin/recover-ubnt-erx.tar.tar.gz", "sysupgrade": "recover-ubnt-erx.tar"},
+        {"role": "squash", "file": "erx_squashfs.tmp", "url": "images/ap/ubnterx/origin/squashfs.tmp"},
+        {"role": "squash_md5", "file": "erx_squashfs.tmp.md5", "url": "images/ap/ubnterx/origin/squashfs.tmp.md5"},
+        {"role": "version", "file": "erx_version.tmp", "url": "images/ap/ubnterx/origin/version.tmp"},
+        {"role": "vmlinux", "file": "erx_vmlinux.tmp", "url": "images/ap/ubnterx/origin/vmlinux.tmp"}
+      ]}
+    }
+  ]
+}
+`
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"image_burner/util"
+)
+
+// From_ubnt_reply makes a device out of a Ubiquiti discovery reply. No
+// login is known yet, ssh detection fills in the rest if it gets in.
+func From_ubnt_reply(r *oakUtility.Ubnt_reply) *Device {
+	dev := &Device{
+		Vendor:      "Ubiquiti",
+		Mac:         r.Mac,
+		IPv4:        r.IPv4,
+		Firmware:    r.Firmware,
+		Description: r.Firmware,
+		HWmodel:     r.Platform,
+		Name:        r.Model,
+	}
+
+	name := "ubnt_ap"
+	model := Match_model(name, r.Systemid)
+	if model == nil {
+		for _, reported := range []string{r.Model, r.Platform} {
+			if model = Match_model("ubnt_erx", reported); model != nil {
+				name = "ubnt_erx"
+				break
+			}
+		}
+	}
+	if dev.Driver = Lookup(name); dev.Driver == nil {
+		return nil
+	}
+	if model != nil {
+		dev.HWmodel = model.ID
+		dev.Name = model.Name
+		dev.LatestFW = latest_version(model.Family)
+	}
+	return dev
+}
+
+// Fill copies what d does not know yet from other
+func (d *Device) Fill(other *Device) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&d.Mac, other.Mac)
+	fill(&d.Firmware, other.Firmware)
+	fill(&d.Description, other.Description)
+	fill(&d.LatestFW, other.LatestFW)
+}
+
+// Unreachable stands for hosts seen on the wire which no driver got into,
+// it is never registered and supports nothing
+type Unreachable struct{}
+
+func (u *Unreachable) Name() string                           { return "none" }
+func (u *Unreachable) Logins() []oakUtility.Login             { return nil }
+func (u *Unreachable) Detect(c *oakUtility.SSHClient) *Device { return nil }
+func (u *Unreachable) Support(d *Device, op Operation) bool   { return false }
+func (u *Unreachable) Convert(d *Device) error                { return ErrNotSupported }
+func (u *Unreachable) Upgrade(d *Device) error                { return ErrNotSupported }
+func (u *Unreachable) Restore(d *Device) error                { return ErrNotSupported }
+
+// From_neighbor makes a device out of an arp entry whose mac vendor we know
+func From_neighbor(n oakUtility.Neighbor, vendor string) *Device {
+	return &Device{
+		Driver:      &Unreachable{},
+		Vendor:      vendor,
+		Mac:         n.Mac,
+		IPv4:        n.IPv4,
+		Description: "no ssh login",
+	}
+}
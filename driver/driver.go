@@ -0,0 +1,302 @@
+// Package driver keeps everything that is model specific: how a device is
+// recognized over ssh and how it is converted, upgraded or restored.
+// Commands only walk the registry, so a new model means one new driver.
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"image_burner/spinner"
+	"image_burner/util"
+	"strings"
+)
+
+type Operation int
+
+const (
+	OP_CONVERT Operation = iota + 1
+	OP_UPGRADE
+	OP_RESTORE
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OP_CONVERT:
+		return "convert"
+	case OP_UPGRADE:
+		return "upgrade"
+	case OP_RESTORE:
+		return "restore"
+	}
+	return "unknown"
+}
+
+// Parse_operation is the operation called name, 0 if none
+func Parse_operation(name string) Operation {
+	for _, op := range []Operation{OP_CONVERT, OP_UPGRADE, OP_RESTORE} {
+		if op.String() == strings.ToLower(name) {
+			return op
+		}
+	}
+	return 0
+}
+
+var ErrNotSupported = errors.New("operation not supported")
+
+var log = oakUtility.New_OakLogger()
+
+func Set_log_level(level string) {
+	log.Set_level(level)
+}
+
+// Device is what a driver found on one host
+type Device struct {
+	Driver      Driver
+	Vendor      string
+	HWmodel     string
+	Name        string
+	Mac         string
+	IPv4        string
+	Firmware    string
+	Description string // firmware or board info, shown in scan list
+	LatestFW    string
+	User        string // credential used during detection
+	Pass        string
+	Port        string       // ssh port
+	Row         *spinner.Row // progress board row while an operation runs, nil for plain spinners
+	Stage       string       // last stage of the operation done, a resumed one carries on after it
+	login       oakUtility.Login
+}
+
+// Login is the credential which worked during detection
+func (d *Device) Login() oakUtility.Login {
+	if d.login.User == "" {
+		return oakUtility.Login{User: d.User, Pass: d.Pass}
+	}
+	return d.login
+}
+
+func (d *Device) OneLineSummary() string {
+	return fmt.Sprintf("%-12s%-16s%-18s%-16s%-25s%s", d.Vendor, d.Name, d.Mac, d.IPv4, d.Description, d.LatestFW)
+}
+
+type Driver interface {
+	Name() string
+	// factory ssh logins of this driver, tried in order after credentials file ones
+	Logins() []oakUtility.Login
+	// look at host over an open session, return nil if host is not handled by this driver
+	Detect(c *oakUtility.SSHClient) *Device
+	Support(d *Device, op Operation) bool
+	Convert(d *Device) error
+	Upgrade(d *Device) error
+	Restore(d *Device) error
+}
+
+// Banner_hinter is for drivers which can tell from the ssh server banner
+// that a host is likely theirs, their logins are tried first
+type Banner_hinter interface {
+	Likely(banner string) bool
+}
+
+var registry []Driver
+
+// Register adds a driver, drivers are probed in register order
+func Register(d Driver) {
+	registry = append(registry, d)
+}
+
+func Drivers() []Driver {
+	return registry
+}
+
+func Lookup(name string) Driver {
+	for _, d := range registry {
+		if d.Name() == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// drivers which like banner first, otherwise keep order
+func order_by_banner(drivers []Driver, banner string) []Driver {
+	var likely, rest []Driver
+	for _, drv := range drivers {
+		if h, ok := drv.(Banner_hinter); ok && h.Likely(banner) {
+			likely = append(likely, drv)
+		} else {
+			rest = append(rest, drv)
+		}
+	}
+	return append(likely, rest...)
+}
+
+var credentials oakUtility.Credentials
+
+// Set_credentials gives logins from a credentials file, tried before driver defaults
+func Set_credentials(creds oakUtility.Credentials) {
+	credentials = creds
+}
+
+// logins of drv in order to try, configured ones first
+func logins(drv Driver) []oakUtility.Login {
+	return append(credentials.For(drv.Name()), drv.Logins()...)
+}
+
+func has_login(drv Driver, l oakUtility.Login) bool {
+	for _, dl := range logins(drv) {
+		if dl == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect reads the ssh banner once, then tries logins in the order drivers
+// like it. Each login is opened once and shared by all drivers taking it.
+func Detect(c *oakUtility.SSHClient, drivers []Driver) *Device {
+	dev, err := detect_pinned(c, drivers)
+	if err != nil {
+		log.Error.Printf("%s: %s\n", c.IPv4, err.Error())
+	}
+	return dev
+}
+
+// detect_pinned is Detect which tells a refused host key apart from no device
+func detect_pinned(c *oakUtility.SSHClient, drivers []Driver) (*Device, error) {
+	if err := c.Connect(); err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil, nil
+	}
+	defer c.Close()
+
+	ordered := order_by_banner(drivers, c.Banner)
+	tried := map[oakUtility.Login]bool{}
+	for _, drv := range ordered {
+		for _, l := range logins(drv) {
+			if tried[l] {
+				continue
+			}
+			tried[l] = true
+			dev := detect_login(c, l, ordered)
+			if dev == nil && c.Refused_key != nil {
+				return nil, c.Refused_key // no other login gets past it
+			}
+			if dev != nil {
+				// mac is known only now, pin or check the key seen at login
+				if err := oakUtility.Verify_host_key(dev.Mac, c.IPv4, c.Host_key); err != nil {
+					return nil, err
+				}
+				return dev, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func detect_login(c *oakUtility.SSHClient, l oakUtility.Login, drivers []Driver) *Device {
+	if err := c.Open_login(l); err != nil {
+		log.Debug.Printf("fail login as %s to %s: %s\n", l.User, c.IPv4, err.Error())
+		return nil
+	}
+	defer c.Close()
+
+	for _, drv := range drivers {
+		if !has_login(drv, l) {
+			continue
+		}
+		if dev := drv.Detect(c); dev != nil {
+			dev.Driver = drv
+			dev.User, dev.Pass, dev.Port, dev.login = l.User, l.Pass, c.Port, l
+			return dev
+		}
+	}
+	return nil
+}
+
+// open_oakridge logs in to Oakridge OS we just flashed, with any login of
+// the oakridge driver
+func open_oakridge(c *oakUtility.SSHClient) error {
+	err := fmt.Errorf("%s: no oakridge login", c.IPv4)
+	for _, l := range logins(Lookup("oakridge")) {
+		if err = c.Open_login(l); err == nil {
+			return nil
+		}
+		log.Debug.Printf("fail login as %s to %s: %s\n", l.User, c.IPv4, err.Error())
+	}
+	return err
+}
+
+// ssh client to where d was found
+func (d *Device) ssh_client() oakUtility.SSHClient {
+	c := oakUtility.New_SSHClient(d.IPv4)
+	c.Mac = d.Mac
+	if d.Port != "" {
+		c.Port = d.Port
+	}
+	return c
+}
+
+// every operation writes firmware which comes up with new host keys,
+// set to forget pinned keys of flashed devices instead of reporting a mismatch
+var Rotate_host_keys bool
+
+// stages an operation records once done, the device can not go back to
+// before them, e.g. it rebooted into another image
+const (
+	STAGE_FACTORY = "factory" // erx convert: LEDE booting through the factory image
+	STAGE_RECOVER = "recover" // erx restore: recover image booting
+	STAGE_FLASHED = "flashed" // new firmware written, only verification left
+)
+
+// On_stage is called each time d finished a stage, e.g. to journal it
+var On_stage func(d *Device)
+
+func (d *Device) stage_done(stage string) {
+	d.Stage = stage
+	if On_stage != nil {
+		On_stage(d)
+	}
+}
+
+// Run dispatches one operation to the driver which detected the device,
+// a device with a Stage resumes after it
+func Run(d *Device, op Operation) error {
+	if d.Driver == nil || !d.Driver.Support(d, op) {
+		return fmt.Errorf("%s %s %s: %s", op, d.IPv4, d.HWmodel, ErrNotSupported)
+	}
+	var err error
+	if d.Stage != STAGE_FLASHED {
+		switch op {
+		case OP_CONVERT:
+			err = d.Driver.Convert(d)
+		case OP_UPGRADE:
+			err = d.Driver.Upgrade(d)
+		case OP_RESTORE:
+			err = d.Driver.Restore(d)
+		default:
+			return ErrNotSupported
+		}
+		if err == nil {
+			d.stage_done(STAGE_FLASHED)
+		}
+	}
+	if err == nil && Rotate_host_keys {
+		if e := oakUtility.Forget_host_key(d.Mac, d.IPv4); e != nil {
+			log.Error.Printf("%s: %s\n", d.IPv4, e.Error())
+		}
+	}
+	if err == nil {
+		err = verify_flash(d, op)
+	}
+	return err
+}
+
+func init() {
+	// probe order matters, an Oakridge device must be recognized before
+	// vendor drivers try their factory credentials on it
+	Register(&Oakridge{})
+	Register(&Unifi_AP{})
+	Register(&Ubnt_ERX{})
+	Register(&QTS_AP{})
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"image_burner/util"
+	"strings"
+)
+
+// Ubnt_ERX handles Ubiquiti EdgeRouters on factory EdgeOS
+type Ubnt_ERX struct{}
+
+func (e *Ubnt_ERX) Name() string {
+	return "ubnt_erx"
+}
+
+func (e *Ubnt_ERX) Logins() []oakUtility.Login {
+	return []oakUtility.Login{{User: "ubnt", Pass: "ubnt"}}
+}
+
+// EdgeOS is debian based and runs OpenSSH
+func (e *Ubnt_ERX) Likely(banner string) bool {
+	return strings.Contains(strings.ToLower(banner), "openssh")
+}
+
+func (e *Ubnt_ERX) Detect(c *oakUtility.SSHClient) *Device {
+
+	dev := Device{Vendor: "Ubiquiti"}
+	var model *Model
+
+	buf, err := c.One_cmd("/opt/vyatta/bin/vyatta-op-cmd-wrapper show version")
+	if err != nil {
+		log.Debug.Printf("%s %s: %s\n", c.IPv4, "show version", err.Error())
+		return nil
+	}
+	// pass output string to get mac and hwmodel
+	tvs := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for _, t := range tvs {
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) < 2 {
+			continue
+		}
+		k := strings.TrimSpace(kv[0])
+		v := strings.TrimSpace(kv[1])
+		log.Debug.Printf("%s %s:%s\n", c.IPv4, k, v)
+		switch k {
+		case "HW S/N":
+			if len(v) == 12 {
+				dev.Mac = v[:2] + ":" + v[2:4] + ":" + v[4:6] + ":" + v[6:8] + ":" + v[8:10] + ":" + v[10:]
+			}
+		case "HW model":
+			model = Match_model(e.Name(), v)
+		case "Version":
+			dev.Firmware = v
+		}
+	}
+	if model == nil {
+		log.Debug.Printf("unsupport erx hw %v\n", tvs)
+		return nil
+	}
+	dev.HWmodel = model.ID
+	dev.Name = model.Name
+	dev.Description = dev.Firmware
+	dev.IPv4 = c.IPv4
+	dev.LatestFW = latest_version(model.Family)
+	return &dev
+}
+
+func (e *Ubnt_ERX) Support(d *Device, op Operation) bool {
+	return op == OP_CONVERT && model_flash(d, op) != nil
+}
+
+func (e *Ubnt_ERX) Convert(d *Device) error {
+	return run_flash(d, OP_CONVERT)
+}
+
+func (e *Ubnt_ERX) Upgrade(d *Device) error {
+	return ErrNotSupported
+}
+
+func (e *Ubnt_ERX) Restore(d *Device) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,515 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"image_burner/ping"
+	"image_burner/spinner"
+	"image_burner/util"
+	"os"
+	"time"
+)
+
+// every "method" a catalog entry may use
+var flash_methods = map[string]func(d *Device, f *Flash) error{
+	"sysupgrade":     flash_sysupgrade,
+	"sysupgrade-oak": flash_sysupgrade_oak,
+	"unifi-kernel":   flash_unifi_kernel,
+	"mtd-firmware":   flash_mtd_firmware,
+	"erx-factory":    flash_erx_factory,
+	"erx-recover":    flash_erx_recover,
+}
+
+// stages of a job on the progress board, spinner titles are their detail
+const (
+	ROW_DOWNLOAD = "download"
+	ROW_COPY     = "copy"
+	ROW_WRITE    = "write flash"
+	ROW_REBOOT   = "reboot"
+	ROW_VERIFY   = "verify"
+)
+
+func (d *Device) stage(stage string) {
+	if d.Row != nil {
+		d.Row.Stage(stage)
+	}
+}
+
+// spinner of d, on its board row when several devices are flashed at once
+func (d *Device) start_spinner(title string) *spinner.Spinner {
+	if d.Row != nil {
+		return d.Row.Spinner(title).Start()
+	}
+	return spinner.StartNew(title)
+}
+
+// message of d, above the board if there is one
+func (d *Device) printf(format string, args ...interface{}) {
+	if d.Row != nil {
+		d.Row.Printf(format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// warning of d, above the board or on stderr
+func (d *Device) warnf(format string, args ...interface{}) {
+	if d.Row != nil {
+		d.Row.Printf("WARNING: "+format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: "+format, args...)
+}
+
+var (
+	Bootup_timeout = 5 * time.Minute
+	Reopen_timeout = 3 * time.Minute // ssh may come up a while after ping
+)
+
+// client of d after it rebooted into an image in between, same port, but
+// that image has host keys of its own, so it is not checked against the pin
+func (d *Device) reboot_client() oakUtility.SSHClient {
+	c := d.ssh_client()
+	c.Mac = ""
+	return c
+}
+
+// log in to the Oakridge image a device just booted
+func reopen_oakridge(c *oakUtility.SSHClient) error {
+	deadline := time.Now().Add(Reopen_timeout)
+	err := c.WaitAndOpen(context.Background(), logins(Lookup("oakridge")), deadline, 2*time.Second)
+	if err == nil {
+		log.Debug.Printf("ssh connected to %s\n", c.IPv4)
+	}
+	return err
+}
+
+// wait until host answers replies pings in a row after a reboot, a device
+// still on its way down breaks the row. where ping is not allowed the
+// fixed wait it used to be is the best we can do
+func wait_bootup(host string, replies int, fallback time.Duration) error {
+	err := ping.WaitUntilReachable(context.Background(), host, replies, Bootup_timeout)
+	if err == nil || ping.IsTimeout(err) {
+		return err
+	}
+	log.Debug.Printf("%s, wait %s instead\n", err.Error(), fallback)
+	time.Sleep(fallback)
+	return nil
+}
+
+// copy local to d, spinner p shows how far, then gets its title back
+func scp_progress(d *Device, c *oakUtility.SSHClient, p *spinner.Spinner, local string, remote string) error {
+	d.stage(ROW_COPY)
+	title := p.Title
+	_, err := c.Scp(local, remote, "0644", func(sent int64, total int64, rate float64) {
+		p.SetProgress("copy "+local+" to "+c.IPv4, sent, total, rate)
+	})
+	p.SetTitle(title)
+	if e, ok := err.(*oakUtility.No_remote_sum); ok {
+		d.warnf("%s, flashing on scp acks alone\n", e.Error())
+		return nil
+	}
+	return err
+}
+
+// find how op is done on d, nil if the catalog does not know
+func model_flash(d *Device, op Operation) *Flash {
+	m := Find_model(d.HWmodel)
+	if m == nil {
+		return nil
+	}
+	return m.Flash(op)
+}
+
+// run_flash downloads and verifies all images op needs and runs its flash method
+func run_flash(d *Device, op Operation) error {
+	f := model_flash(d, op)
+	if f == nil || len(f.Images) == 0 {
+		return ErrNotSupported
+	}
+	d.stage(ROW_DOWNLOAD)
+	var rep oakUtility.Reporter // progress and messages on the row, if any
+	if d.Row != nil {
+		rep = d.Row
+	}
+	for _, img := range f.Images {
+		// never let an image we can not vouch for reach the device
+		if err := oakUtility.Download_image_to(img.File, img.URL, rep); err != nil {
+			return fmt.Errorf("download %s fail: %s", img.URL, err.Error())
+		}
+	}
+	return flash_methods[f.Method](d, f)
+}
+
+// 3rd party firmware which still has OpenWrt sysupgrade, e.g. QTS ap152 boards
+func flash_sysupgrade(d *Device, f *Flash) error {
+	img := f.Images[0]
+
+	log.Info.Printf("install %s %s from %s\n", d.IPv4, img.File, img.URL)
+
+	c := d.ssh_client()
+	if err := c.Open_login(d.Login()); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	p := d.start_spinner("copy img ...")
+
+	if err := scp_progress(d, &c, p, img.File, "/tmp/"+img.File); err != nil {
+		p.Stop()
+		return err
+	}
+	p.Stop()
+
+	d.stage(ROW_WRITE)
+	d.printf("\nWriting flash, MUST NOT POWER OFF, it might take several minutes!\n")
+	p.SetTitle("writing flash ...")
+	p.Start()
+	defer p.Stop()
+
+	if _, err := c.One_cmd("tar xzf /tmp/" + img.File + " -C /tmp"); err != nil {
+		return err
+	}
+	// sysupgrade never returns cleanly, device reboots under us
+	buf, err := c.One_cmd("sysupgrade -n /tmp/" + img.Sysupgrade)
+	log.Debug.Printf("<%s> %v\n", string(buf), err)
+	return nil
+}
+
+// Oakridge OS to a newer Oakridge OS
+func flash_sysupgrade_oak(d *Device, f *Flash) error {
+	img := f.Images[0]
+
+	p := d.start_spinner("Upgrade " + d.IPv4 + " " + d.HWmodel + " ...")
+	defer p.Stop()
+
+	c := d.ssh_client()
+	if err := c.Open_login(d.Login()); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	remotefile := "/tmp/oak.tar.gz"
+	if err := scp_progress(d, &c, p, img.File, remotefile); err != nil {
+		return err
+	}
+
+	d.stage(ROW_WRITE)
+	d.printf("\nWrite flash, MUST NOT POWER OFF, it might take several minutes!\n")
+
+	var cmds = [][]string{
+		{"echo 'Auto Upgrade Now...'|logger -p2", "optional"},
+		{"stop", "optional"},
+		{"/etc/init.d/capwap stop", "optional"},
+		{"/etc/init.d/handle_cloud stop", "optional"},
+		{"/etc/init.d/wifidog stop", "optional"},
+		{"/etc/init.d/arpwatch stop", "optional"},
+		{"tar xzf " + remotefile + " -C /tmp", "mandatory"},
+		{"rm -rvf " + remotefile, "mandatory"},
+		{"sysupgrade -n /tmp/" + img.Sysupgrade, "mandatory"},
+	}
+	for _, cmd := range cmds {
+		buf, err := c.One_cmd(cmd[0])
+		if err != nil {
+			log.Debug.Printf("\n%v: %s <%s>\n", cmd, err.Error(), string(buf))
+			// sysupgrade drops the session, that EOF is expected
+			if err.Error() != "EOF" && cmd[1] == "mandatory" {
+				return err
+			}
+		}
+	}
+	d.printf("\n%s upgrade image, please waiting boot up\n", d.IPv4)
+	return nil
+}
+
+// UniFi factory firmware has no sysupgrade, split image into both kernel partitions
+func flash_unifi_kernel(d *Device, f *Flash) error {
+	img := f.Images[0]
+
+	p := d.start_spinner("Install " + d.IPv4 + " ...")
+	defer p.Stop()
+
+	c := d.ssh_client()
+	if err := c.Open_login(d.Login()); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	remotefile := "/tmp/oakridge.tar.gz"
+	if err := scp_progress(d, &c, p, img.File, remotefile); err != nil {
+		return err
+	}
+
+	d.stage(ROW_WRITE)
+	d.printf("\nWriting flash, MUST NOT POWER OFF, it might take several minutes!\n")
+
+	var cmds = []string{
+		"tar xzf " + remotefile + " -C /tmp",
+		"rm -rvf " + remotefile,
+		"dd if=/tmp/" + img.Sysupgrade + " of=/tmp/kernel0.bin bs=7929856 count=1",
+		"dd if=/tmp/" + img.Sysupgrade + " of=/tmp/kernel1.bin bs=7929856 count=1 skip=1",
+		"mtd write /tmp/kernel0.bin kernel0",
+		"mtd write /tmp/kernel1.bin kernel1",
+		"reboot",
+	}
+	for _, cmd := range cmds {
+		if _, err := c.One_cmd(cmd); err != nil {
+			return fmt.Errorf("%s: %s", cmd, err.Error())
+		}
+	}
+	d.printf("\n%s upgraded to Oakridge OS, please power cycle device\n", d.IPv4)
+	return nil
+}
+
+// Oakridge OS back to factory firmware, write whole firmware partition
+func flash_mtd_firmware(d *Device, f *Flash) error {
+	img := f.Images[0]
+
+	p := d.start_spinner("Restore " + d.IPv4 + " " + d.HWmodel + " ...")
+	defer p.Stop()
+
+	c := d.ssh_client()
+	if err := c.Open_login(d.Login()); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var cmds = [][]string{
+		{"stop", "optional"},
+		{"/etc/init.d/supervisor stop", "optional"},
+		{"/etc/init.d/capwap stop", "optional"},
+		{"/etc/init.d/handle_cloud stop", "optional"},
+		{"/etc/init.d/wifidog stop", "optional"},
+		{"/etc/init.d/arpwatch stop", "optional"},
+	}
+	for _, cmd := range cmds {
+		buf, err := c.One_cmd(cmd[0])
+		if err != nil {
+			log.Debug.Printf("\n%v: %s <%s>\n", cmd, err.Error(), string(buf))
+		}
+	}
+
+	remotefile := "/tmp/oak.tar.gz"
+	if err := scp_progress(d, &c, p, img.File, remotefile); err != nil {
+		return err
+	}
+
+	d.stage(ROW_WRITE)
+	d.printf("\nWrite flash, MUST NOT POWER OFF, it might take several minutes!\n")
+
+	cmds = [][]string{
+		{"stop", "optional"},
+		{"/etc/init.d/capwap stop", "optional"},
+		{"/etc/init.d/handle_cloud stop", "optional"},
+		{"/etc/init.d/wifidog stop", "optional"},
+		{"/etc/init.d/arpwatch stop", "optional"},
+		{"tar xzf " + remotefile + " -C /tmp", "mandatory"},
+		{"rm -rvf " + remotefile, "mandatory"},
+		{"mtd write /tmp/" + img.Sysupgrade + " firmware", "mandatory"},
+		{"reboot", "mandatory"},
+	}
+	for _, cmd := range cmds {
+		buf, err := c.One_cmd(cmd[0])
+		if err != nil {
+			log.Debug.Printf("\n%v: %s <%s>\n", cmd, err.Error(), string(buf))
+			if cmd[1] == "mandatory" {
+				return err
+			}
+		}
+	}
+	d.printf("\n%s restored to factory image, please power cycle device\n", d.IPv4)
+	return nil
+}
+
+// EdgeOS can only boot a signed factory image, so boot LEDE initramfs
+// through it first, then sysupgrade to Oakridge from there
+func flash_erx_factory(d *Device, f *Flash) error {
+	factory := f.Image("factory")
+	oakridge := f.Image("oakridge")
+	if factory == nil || oakridge == nil {
+		return fmt.Errorf("%s: catalog needs factory and oakridge images", d.HWmodel)
+	}
+
+	if d.Stage != STAGE_FACTORY {
+		if err := erx_factory_img(d, factory); err != nil {
+			return err
+		}
+		d.stage_done(STAGE_FACTORY)
+	}
+
+	d.stage(ROW_REBOOT)
+	p := d.start_spinner("Wait device bootup ...")
+	err := wait_bootup(d.IPv4, 35, 65*time.Second)
+	p.Stop()
+	if err != nil {
+		return err
+	}
+
+	p.SetTitle("Install Oakridge img ...")
+	p.Start()
+	defer p.Stop()
+	c := d.reboot_client() // ssh back to device again
+	if err := reopen_oakridge(&c); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	file := oakridge.File
+	if err := scp_progress(d, &c, p, file, "/tmp/"+file); err != nil {
+		return err
+	}
+	d.stage(ROW_WRITE)
+	if _, err := c.One_cmd("tar xzf /tmp/" + file + " -C /tmp"); err != nil {
+		return err
+	}
+	c.One_cmd("sysupgrade -n " + oakridge.Sysupgrade)
+	return nil
+}
+
+func erx_factory_img(d *Device, img *Image) error {
+
+	p := d.start_spinner("Install factory img ...")
+	defer p.Stop()
+
+	c := d.ssh_client()
+	if err := c.Open_login(d.Login()); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	file := img.File
+	if err := scp_progress(d, &c, p, file, "/tmp/"+file); err != nil {
+		return err
+	}
+	log.Debug.Printf("done scp %s to %s:%s\n", file, d.IPv4, "/tmp/"+file)
+
+	if _, err := c.One_cmd("tar xzf /tmp/" + file + " -C /tmp"); err != nil {
+		return err
+	}
+	log.Debug.Printf("done untar %s:%s\n", d.IPv4, "/tmp/"+file)
+
+	d.stage(ROW_WRITE)
+	if buf, err := c.One_cmd("/opt/vyatta/bin/vyatta-op-cmd-wrapper add system image /tmp/" + img.Sysupgrade); err != nil {
+		return fmt.Errorf("%s %s", string(buf), err.Error())
+	}
+
+	d.stage(ROW_REBOOT)
+	if _, err := c.One_cmd("/opt/vyatta/bin/vyatta-op-cmd-wrapper reboot now"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Oakridge OS back to EdgeOS, boot a recover image first which can rebuild the ubi volume
+func flash_erx_recover(d *Device, f *Flash) error {
+	files := map[string]string{}
+	for _, role := range []string{"recover", "squash", "squash_md5", "version", "vmlinux"} {
+		img := f.Image(role)
+		if img == nil {
+			return fmt.Errorf("%s: catalog needs %s image", d.HWmodel, role)
+		}
+		files[role] = img.File
+	}
+	squash := files["squash"]
+	squash_md5 := files["squash_md5"]
+	version := files["version"]
+	vmlinux := files["vmlinux"]
+
+	host := d.IPv4
+	log.Debug.Printf("Start restore %s\n", host)
+
+	if d.Stage != STAGE_RECOVER {
+		if err := ubnt_recover_img(d, host, f.Image("recover")); err != nil { // recover img and reboot
+			return err
+		}
+		d.stage_done(STAGE_RECOVER)
+	}
+
+	d.stage(ROW_REBOOT)
+	p := d.start_spinner("Wait device bootup ...")
+	err := wait_bootup(host, 30, 50*time.Second)
+	p.Stop()
+	if err != nil {
+		return err
+	}
+
+	p.SetTitle("Restoring factory img ...")
+	p.Start()
+	defer p.Stop()
+	c := d.reboot_client() // ssh back to device again
+	if err := reopen_oakridge(&c); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, file := range []string{squash, squash_md5, version, vmlinux} { // scp other file to device
+		if err := scp_progress(d, &c, p, file, "/tmp/"+file); err != nil {
+			return err
+		}
+		log.Debug.Printf("done scp %s to %s:%s\n", file, host, "/tmp/"+file)
+	}
+	d.stage(ROW_WRITE)
+	var cmds = []string{
+		"ubidetach -m 5",
+		"ubiformat /dev/mtd5",
+		"ubiattach -p /dev/mtd5",
+		"ubimkvol /dev/ubi0 --vol_id=0 --lebs=1925 --name=troot",
+		"mount -o sync -t ubifs ubi0:troot /mnt",
+		"mtd write /tmp/" + vmlinux + " kernel1",
+		"mtd write /tmp/" + vmlinux + " kernel2",
+		"cp /tmp/" + version + " /mnt/version",
+		"cp /tmp/" + squash + " /mnt/squashfs.img",
+		"cp /tmp/" + squash_md5 + " /mnt/squashfs.img.md5",
+		"reboot",
+	}
+	for _, cmd := range cmds {
+		log.Debug.Printf("%s ...\n", cmd)
+		if _, err := c.One_cmd(cmd); err != nil {
+			return fmt.Errorf("%s: %s", cmd, err.Error())
+		}
+	}
+	d.printf("\nDevice restored to factory image successfully\n")
+	return nil
+}
+
+func ubnt_recover_img(d *Device, host string, img *Image) error {
+
+	p := d.start_spinner("Install recover img ...")
+	defer p.Stop()
+
+	c := d.ssh_client()
+	if err := open_oakridge(&c); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var cmds = []string{
+		"stop",
+		"/etc/init.d/supervisor stop",
+		"/etc/init.d/capwap stop",
+		"/etc/init.d/handle_cloud stop",
+		"/etc/init.d/wifidog stop",
+		"/etc/init.d/arpwatch stop",
+	}
+	for _, cmd := range cmds {
+		buf, err := c.One_cmd(cmd)
+		if err != nil {
+			log.Debug.Printf("\n%v: %s <%s>\n", cmd, err.Error(), string(buf))
+		}
+	}
+
+	file := img.File
+	if err := scp_progress(d, &c, p, file, "/tmp/"+file); err != nil {
+		return err
+	}
+	log.Debug.Printf("done scp %s to %s:%s\n", file, host, "/tmp/"+file)
+	if _, err := c.One_cmd("tar xzf /tmp/" + file + " -C /tmp"); err != nil {
+		return err
+	}
+	log.Debug.Printf("done untar %s:%s\n", host, "/tmp/"+file)
+	d.stage(ROW_WRITE)
+	//last cmd expect return err
+	log.Debug.Printf("sysupgrade -n /tmp/%s", img.Sysupgrade)
+	c.One_cmd("sysupgrade -n /tmp/" + img.Sysupgrade)
+	return nil
+}
@@ -0,0 +1,23 @@
+package driver
+
+// old Oakridge firmware reports some models by another name, the catalog
+// keeps those as aliases
+func Canonical_model(model string) string {
+	if m := Find_model(model); m != nil {
+		return m.ID
+	}
+	return model
+}
+
+func Model_to_name(model string) string {
+	if m := Find_model(model); m != nil && m.Name != "" {
+		return m.Name
+	}
+	return "QTS_" + model
+}
+
+// routers are not managed as AP, e.g. not exported to the AP list
+func (d *Device) Is_ap() bool {
+	m := Find_model(d.HWmodel)
+	return m == nil || m.Type != "router"
+}
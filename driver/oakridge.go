@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"image_burner/util"
+	"strings"
+)
+
+// Oakridge handles devices already running Oakridge OS
+type Oakridge struct{}
+
+func (o *Oakridge) Name() string {
+	return "oakridge"
+}
+
+func (o *Oakridge) Logins() []oakUtility.Login {
+	return []oakUtility.Login{{User: "root", Pass: "oakridge"}}
+}
+
+// Oakridge OS is OpenWrt based and runs dropbear
+func (o *Oakridge) Likely(banner string) bool {
+	return strings.Contains(strings.ToLower(banner), "dropbear")
+}
+
+func (o *Oakridge) Detect(c *oakUtility.SSHClient) *Device {
+
+	dev := Device{Vendor: "Oakridge"}
+
+	// mac-addr
+	buf, err := c.One_cmd("uci get productinfo.productinfo.mac")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+	dev.Mac = strings.TrimSpace(string(buf))
+
+	buf, err = c.One_cmd("uci get productinfo.productinfo.production")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+	dev.HWmodel = strings.TrimSpace(string(buf))
+
+	buf, err = c.One_cmd("uci get productinfo.productinfo.model")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		dev.Name = Model_to_name(dev.HWmodel)
+	} else {
+		dev.Name = strings.TrimSpace(string(buf))
+	}
+
+	buf, err = c.One_cmd("uci get productinfo.productinfo.bootversion")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		buf, err = c.One_cmd("uci get productinfo.productinfo.swversion")
+		if err != nil {
+			log.Debug.Printf("uci get productinfo.productinfo.swversion: %s\n", err.Error())
+			return nil
+		}
+	}
+	dev.Firmware = strings.TrimSpace(string(buf))
+	dev.Description = dev.Firmware
+
+	dev.IPv4 = c.IPv4
+	if m := Find_model(dev.HWmodel); m != nil {
+		dev.LatestFW = latest_version(m.Family)
+	}
+	return &dev
+}
+
+func (o *Oakridge) Support(d *Device, op Operation) bool {
+	if op != OP_UPGRADE && op != OP_RESTORE {
+		return false
+	}
+	return model_flash(d, op) != nil
+}
+
+func (o *Oakridge) Convert(d *Device) error {
+	return ErrNotSupported
+}
+
+func (o *Oakridge) Upgrade(d *Device) error {
+	return run_flash(d, OP_UPGRADE)
+}
+
+func (o *Oakridge) Restore(d *Device) error {
+	return run_flash(d, OP_RESTORE)
+}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"image_burner/util"
+	"strings"
+)
+
+// QTS_AP handles QTS and DCN OEM APs, they all run an ap152 based firmware
+type QTS_AP struct{}
+
+func (q *QTS_AP) Name() string {
+	return "qts"
+}
+
+func (q *QTS_AP) Logins() []oakUtility.Login {
+	return []oakUtility.Login{{User: "admin", Pass: "admin"}}
+}
+
+func (q *QTS_AP) Detect(c *oakUtility.SSHClient) *Device {
+
+	// login shell is a CLI, swap it for ash and log in again so commands below work
+	if err := c.SSHFixup(); err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+	}
+	if err := c.Reopen(); err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+
+	buf, err := c.One_cmd("strings /dev/mtd5 | grep =")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+
+	// now we parse the <key>=<value>
+	dev := Device{}
+	var board_sn, manufact_date, devname string
+	tvs := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for _, t := range tvs {
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) < 2 {
+			continue
+		}
+		k := strings.TrimSpace(kv[0])
+		v := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch k {
+		case "MAC_ADDRESS":
+			dev.Mac = v
+		case "VENDOR_NAME":
+			dev.Vendor = v
+		case "DEV_NAME":
+			devname = v
+		case "BOARD_SERIAL_NUMBER":
+			board_sn = v
+		case "MANUFACTURING_DATE":
+			manufact_date = v
+		}
+	}
+
+	model := Match_model(q.Name(), devname)
+	if model == nil {
+		return nil
+	}
+	dev.HWmodel = model.ID
+	dev.Name = model.Name
+	dev.Description = manufact_date + " " + board_sn
+	dev.IPv4 = c.IPv4
+	dev.LatestFW = latest_version(model.Family)
+	log.Debug.Printf("%v\n", dev)
+	return &dev
+}
+
+func (q *QTS_AP) Support(d *Device, op Operation) bool {
+	return op == OP_CONVERT && model_flash(d, op) != nil
+}
+
+func (q *QTS_AP) Convert(d *Device) error {
+	return run_flash(d, OP_CONVERT)
+}
+
+func (q *QTS_AP) Upgrade(d *Device) error {
+	return ErrNotSupported
+}
+
+func (q *QTS_AP) Restore(d *Device) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"fmt"
+	"image_burner/util"
+	"net"
+	"strings"
+)
+
+/*
+ * device selection expression for scripted runs, e.g.
+ *   all
+ *   model=AC-PRO,ip=10.1.1.0/28
+ *   mac=00:11:22:33:44:55
+ * terms are comma separated and must all match
+ */
+type Selector struct {
+	terms []sel_term
+}
+
+type sel_term struct {
+	key   string
+	value string
+	ipnet *net.IPNet
+}
+
+func normalize_mac(mac string) string {
+	r := strings.NewReplacer(":", "", "-", "", ".", "")
+	return strings.ToLower(r.Replace(strings.TrimSpace(mac)))
+}
+
+func Parse_selector(expr string) (*Selector, error) {
+	s := &Selector{}
+	for _, t := range strings.Split(expr, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || strings.EqualFold(t, "all") {
+			continue
+		}
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("bad select term %q, want all|mac=|model=|ip=", t)
+		}
+		term := sel_term{key: strings.ToLower(kv[0]), value: strings.TrimSpace(kv[1])}
+		switch term.key {
+		case "mac":
+			term.value = normalize_mac(term.value)
+		case "model":
+		case "ip":
+			cidr, err := oakUtility.String2netstring(term.value)
+			if err != nil {
+				return nil, fmt.Errorf("bad select term %q: %s", t, err.Error())
+			}
+			_, term.ipnet, _ = net.ParseCIDR(cidr)
+		default:
+			return nil, fmt.Errorf("bad select term %q, want all|mac=|model=|ip=", t)
+		}
+		s.terms = append(s.terms, term)
+	}
+	return s, nil
+}
+
+func (t *sel_term) match(d *Device) bool {
+	switch t.key {
+	case "mac":
+		return normalize_mac(d.Mac) == t.value
+	case "model":
+		return strings.EqualFold(Canonical_model(t.value), Canonical_model(d.HWmodel)) ||
+			strings.EqualFold(t.value, d.HWmodel) || strings.EqualFold(t.value, d.Name)
+	case "ip":
+		ip := net.ParseIP(d.IPv4)
+		return ip != nil && t.ipnet.Contains(ip)
+	}
+	return false
+}
+
+func (s *Selector) Match(d *Device) bool {
+	for i := range s.terms {
+		if !s.terms[i].match(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns devices matching expr, keeping their order
+func Select(devs []*Device, expr string) ([]*Device, error) {
+	s, err := Parse_selector(expr)
+	if err != nil {
+		return nil, err
+	}
+	var picked []*Device
+	for _, d := range devs {
+		if s.Match(d) {
+			picked = append(picked, d)
+		}
+	}
+	return picked, nil
+}
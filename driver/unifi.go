@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"image_burner/util"
+	"strings"
+)
+
+// Unifi_AP handles Ubiquiti UniFi APs on factory firmware
+type Unifi_AP struct{}
+
+func (u *Unifi_AP) Name() string {
+	return "ubnt_ap"
+}
+
+func (u *Unifi_AP) Logins() []oakUtility.Login {
+	return []oakUtility.Login{{User: "ubnt", Pass: "ubnt"}}
+}
+
+// UniFi firmware runs dropbear
+func (u *Unifi_AP) Likely(banner string) bool {
+	return strings.Contains(strings.ToLower(banner), "dropbear")
+}
+
+func (u *Unifi_AP) Detect(c *oakUtility.SSHClient) *Device {
+
+	dev := Device{Vendor: "Ubiquiti"}
+	var model *Model
+
+	buf, err := c.One_cmd("cat /proc/ubnthal/system.info")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+	// pass output string to get mac and hwmodel
+	tvs := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for _, t := range tvs {
+		v := strings.Split(t, "=")
+		if len(v) < 2 {
+			continue
+		}
+		switch v[0] {
+		case "eth0.macaddr":
+			dev.Mac = v[1]
+		case "systemid":
+			if model = Match_model(u.Name(), v[1]); model == nil {
+				// only support model in catalog
+				log.Debug.Printf("%s: %s not support\n", c.IPv4, v[1])
+				return nil
+			}
+		}
+	}
+	if model == nil {
+		return nil
+	}
+	dev.HWmodel = model.ID
+	dev.Name = model.Name
+
+	// sw ver
+	buf, err = c.One_cmd("cat /etc/version")
+	if err != nil {
+		log.Debug.Printf("%s: %s\n", c.IPv4, err.Error())
+		return nil
+	}
+	dev.Firmware = strings.TrimSpace(string(buf))
+	dev.Description = dev.Firmware
+	dev.IPv4 = c.IPv4
+	dev.LatestFW = latest_version(model.Family)
+	return &dev
+}
+
+func (u *Unifi_AP) Support(d *Device, op Operation) bool {
+	return op == OP_CONVERT && model_flash(d, op) != nil
+}
+
+func (u *Unifi_AP) Convert(d *Device) error {
+	return run_flash(d, OP_CONVERT)
+}
+
+func (u *Unifi_AP) Upgrade(d *Device) error {
+	return ErrNotSupported
+}
+
+func (u *Unifi_AP) Restore(d *Device) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"image_burner/ping"
+	"image_burner/util"
+	"time"
+)
+
+/*
+ * a flash only ends with the device rebooting, verify waits until it answers
+ * ping and ssh again and looks at it with the driver of the new firmware:
+ * same mac, and Oakridge latest version after convert/upgrade or factory
+ * firmware after restore
+ */
+var (
+	Verify_timeout = 10 * time.Minute // 0 skips verification
+	Verify_settle  = 10 * time.Second // device is still up right after the flash command
+	Verify_poll    = 5 * time.Second
+)
+
+// Verify_timeout_error is a device which did not come back in time
+type Verify_timeout_error struct {
+	IPv4 string
+	Last string // what was seen last, if anything
+}
+
+func (e *Verify_timeout_error) Error() string {
+	if e.Last != "" {
+		return fmt.Sprintf("%s timed out, %s", e.IPv4, e.Last)
+	}
+	return fmt.Sprintf("%s timed out, device did not come back", e.IPv4)
+}
+
+// Timed_out is a device which did not come back after a flash or a reboot in between
+func Timed_out(err error) bool {
+	switch err.(type) {
+	case *Verify_timeout_error, *oakUtility.Open_timeout_error:
+		return true
+	}
+	return ping.IsTimeout(err)
+}
+
+// drivers which should recognize d once op is done
+func drivers_after(d *Device, op Operation) []Driver {
+	if op != OP_RESTORE {
+		return []Driver{Lookup("oakridge")}
+	}
+	if m := Find_model(d.HWmodel); m != nil {
+		if drv := Lookup(m.Driver); drv != nil {
+			return []Driver{drv}
+		}
+	}
+	var vendors []Driver
+	for _, drv := range registry {
+		if drv.Name() != "oakridge" {
+			vendors = append(vendors, drv)
+		}
+	}
+	return vendors
+}
+
+// wait for one echo reply before timeout, usable is false if ping is not allowed here
+func wait_ping(host string, timeout time.Duration) (replied bool, usable bool) {
+	err := ping.WaitUntilReachable(context.Background(), host, 1, timeout)
+	return err == nil, err == nil || ping.IsTimeout(err)
+}
+
+func verify_flash(d *Device, op Operation) error {
+	if Verify_timeout <= 0 {
+		return nil
+	}
+	d.stage(ROW_REBOOT)
+	p := d.start_spinner("Verify " + d.IPv4 + " ...")
+	defer p.Stop()
+
+	deadline := time.Now().Add(Verify_timeout)
+	expected := drivers_after(d, op)
+	last := ""
+	pinging := true // until we find we may not
+	time.Sleep(Verify_settle)
+	for time.Now().Before(deadline) {
+		if pinging {
+			replied, usable := wait_ping(d.IPv4, time.Until(deadline))
+			pinging = usable
+			if !replied {
+				log.Debug.Printf("%s: no ping reply\n", d.IPv4)
+			}
+		}
+		d.stage(ROW_VERIFY)
+		// new firmware has its own host key, it is checked by mac on detection.
+		// refused under strict policy it stays refused, no point to wait
+		c := d.ssh_client()
+		dev, err := detect_pinned(&c, expected)
+		if err != nil {
+			return err
+		}
+		if dev != nil {
+			done, err := check_flashed(d, op, dev)
+			if done {
+				return err
+			}
+			last = "still on " + dev.Firmware
+		}
+		time.Sleep(Verify_poll)
+	}
+	return &Verify_timeout_error{IPv4: d.IPv4, Last: last}
+}
+
+// done is false while dev still runs the firmware d had before op
+func check_flashed(d *Device, op Operation, dev *Device) (bool, error) {
+	if d.Mac != "" && normalize_mac(dev.Mac) != normalize_mac(d.Mac) {
+		return true, fmt.Errorf("%s came back as %s, not %s", d.IPv4, dev.Mac, d.Mac)
+	}
+	if dev.Firmware == d.Firmware {
+		return false, nil
+	}
+	if op != OP_RESTORE && d.LatestFW != "" && dev.Firmware != d.LatestFW {
+		return true, fmt.Errorf("%s came back on %s, expected %s", d.IPv4, dev.Firmware, d.LatestFW)
+	}
+	log.Info.Printf("%s verified on %s %s\n", d.IPv4, dev.Vendor, dev.Firmware)
+	d.Firmware = dev.Firmware
+	return true, nil
+}
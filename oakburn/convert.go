@@ -0,0 +1,58 @@
+package main
+
+import (
+	"image_burner/burner"
+	"image_burner/driver"
+)
+
+var converted_ap burner.Inventory // remember all new converted AP
+
+func install_one_device(d *driver.Device) error {
+	if err := driver.Run(d, driver.OP_CONVERT); err != nil {
+		return err
+	}
+	// routers do not go into the AP list
+	if d.Is_ap() {
+		converted_ap.Add(d)
+	}
+	return nil
+}
+
+func cmd_convert(args []string) int {
+	fs, o := new_flagset("convert", FLAGS_SCAN|FLAGS_OUTPUT|FLAGS_SELECT)
+	fs.Parse(args)
+	burner.Cleanup()
+	burner.Prepare_sshconf()
+	setup(o)
+
+	netlist, err := burner.Scan_networks(fs.Args(), driver.Drivers())
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	devs := burner.Devices(netlist)
+	burner.Report_devices(devs, driver.OP_CONVERT)
+	return convert_devices(devs, o)
+}
+
+func convert_devices(devs []*driver.Device, o *burner.Options) int {
+	failed := 0
+	targets := burner.Job_targets(devs, driver.OP_CONVERT, "convert", o)
+	if len(targets) > 0 {
+		println("\n**To do convert Vendor devices now**\n")
+		failed = burner.Run_all(targets, driver.OP_CONVERT, install_one_device)
+
+		var macs []string
+		for _, ap := range converted_ap.List() {
+			macs = append(macs, ap.Mac)
+		}
+		burner.Write_OakAP_csv(devs, macs)
+	}
+
+	burner.Flush_output()
+
+	println(burner.Banner_end)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image_burner/util"
+	"io/ioutil"
+	"os"
+)
+
+// seal a plain credentials file so it can be kept on a laptop or in a repo
+func cmd_credentials(args []string) int {
+	fs := flag.NewFlagSet("credentials", flag.ExitOnError)
+	output := fs.String("o", "credentials.sealed.json", "sealed credentials `file` to write")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s credentials [-o file] plain.json\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	plain, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	pass, err := oakUtility.Credentials_passphrase("New passphrase: ")
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	if os.Getenv("OAK_CREDENTIALS_PASSPHRASE") == "" {
+		again, err := oakUtility.Credentials_passphrase("Again: ")
+		if err != nil {
+			log.Error.Fatalln(err)
+		}
+		if again != pass {
+			log.Error.Fatalln("passphrases do not match")
+		}
+	}
+	if pass == "" {
+		log.Error.Fatalln("empty passphrase")
+	}
+
+	sealed, err := oakUtility.Seal_credentials(plain, pass)
+	if err != nil {
+		log.Error.Fatalf("%s: %s\n", fs.Arg(0), err.Error())
+	}
+	if err := ioutil.WriteFile(*output, sealed, 0600); err != nil {
+		log.Error.Fatalln(err)
+	}
+	fmt.Printf("sealed credentials saved in %s\n", *output)
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image_burner/burner"
+	"image_burner/driver"
+	"image_burner/util"
+	"os"
+)
+
+var log oakUtility.OakLogger
+
+type command struct {
+	name string
+	help string
+	run  func(args []string) int
+}
+
+var commands = []command{
+	{"scan", "list devices found in subnets", cmd_scan},
+	{"convert", "convert 3rd-party devices to Oakridge firmware", cmd_convert},
+	{"upgrade", "upgrade Oakridge devices to latest firmware", cmd_upgrade},
+	{"restore", "restore Oakridge devices to vendor firmware", cmd_restore},
+	{"inventory", "save all found devices to a csv file", cmd_inventory},
+	{"verify", "check downloaded images against SHA256SUMS", cmd_verify},
+	{"bundle", "pack images for offline sites", cmd_bundle},
+	{"credentials", "encrypt a credentials file with a passphrase", cmd_credentials},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [subnet|host ...]\n\ncommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for its flags. Without a command, or with a subnet or\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "host first, it scans and runs --operation convert|upgrade|restore, or asks which one\n")
+}
+
+const (
+	FLAGS_SCAN   = 1 << iota // --concurrency, --rate
+	FLAGS_OUTPUT             // --output
+	FLAGS_SELECT             // --select, --yes
+)
+
+// new_flagset for command name, with flags all commands share
+func new_flagset(name string, flags int) (*flag.FlagSet, *burner.Options) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	o := burner.Add_flags(fs)
+	if flags&FLAGS_SCAN != 0 {
+		o.Scan_flags(fs)
+	}
+	if flags&FLAGS_OUTPUT != 0 {
+		o.Output_flags(fs)
+	}
+	if flags&FLAGS_SELECT != 0 {
+		o.Select_flags(fs)
+	}
+	return fs, o
+}
+
+func setup(o *burner.Options) {
+	if err := o.Setup(); err != nil {
+		log.Error.Fatalln(err)
+	}
+	println(burner.Banner_start)
+}
+
+func init() {
+	log = oakUtility.New_OakLogger()
+	log.Set_level("error")
+	burner.Set_log_level("error")
+}
+
+// no command: scan with all drivers, then run --operation, or ask which one
+// if found devices allow several. --select without --operation converts
+func cmd_default(args []string) int {
+	fs, o := new_flagset("oakburn", FLAGS_SCAN|FLAGS_OUTPUT|FLAGS_SELECT)
+	operation := fs.String("operation", "", "`convert|upgrade|restore`, skip the operation menu")
+	fs.Parse(args)
+	op := driver.Operation(0)
+	if *operation != "" {
+		if op = driver.Parse_operation(*operation); op == 0 {
+			log.Error.Fatalf("unknown operation %s, want convert|upgrade|restore\n", *operation)
+		}
+	} else if o.Select != "" || o.Yes {
+		op = driver.OP_CONVERT
+	}
+	burner.Cleanup()
+	burner.Prepare_sshconf()
+	setup(o)
+
+	netlist, err := burner.Scan_networks(fs.Args(), driver.Drivers())
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	devs := burner.Devices(netlist)
+	if op == 0 && !burner.Machine_output() {
+		burner.List_devices(devs, 0)
+		if op = burner.Choose_operation(devs); op == 0 {
+			println(burner.Banner_end)
+			return 0
+		}
+	} else {
+		if op == 0 {
+			op = driver.OP_CONVERT
+		}
+		burner.Report_devices(devs, op)
+	}
+	if op == driver.OP_CONVERT {
+		return convert_devices(devs, o)
+	}
+	return oak_devices(op.String(), op, devs, o)
+}
+
+func main() {
+	// double click on windows gives no args, it scans and asks what to do
+	args := os.Args[1:]
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				os.Exit(c.run(args[1:]))
+			}
+		}
+		switch args[0] {
+		case "-h", "-help", "--help", "help":
+			usage()
+			os.Exit(0)
+		}
+	}
+	// flags or targets without a command
+	os.Exit(cmd_default(args))
+}
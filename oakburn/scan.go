@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image_burner/burner"
+	"image_burner/driver"
+)
+
+func cmd_scan(args []string) int {
+	fs, o := new_flagset("scan", FLAGS_SCAN|FLAGS_OUTPUT)
+	fs.Parse(args)
+	burner.Cleanup()
+	setup(o)
+
+	netlist, err := burner.Scan_networks(fs.Args(), driver.Drivers())
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	burner.Report_devices(burner.Devices(netlist), 0)
+	burner.Flush_output()
+
+	println(burner.Banner_end)
+	return 0
+}
+
+func cmd_inventory(args []string) int {
+	fs, o := new_flagset("inventory", FLAGS_SCAN|FLAGS_OUTPUT)
+	output := fs.String("o", "inventory.csv", "csv `file` to write")
+	fs.Parse(args)
+	burner.Cleanup()
+	setup(o)
+
+	netlist, err := burner.Scan_networks(fs.Args(), driver.Drivers())
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	devs := burner.Devices(netlist)
+	burner.Report_devices(devs, 0)
+	burner.Flush_output()
+
+	if err := burner.Write_inventory(*output, devs); err != nil {
+		log.Error.Println(err)
+		return 1
+	}
+	println("\n", len(devs), "devices saved in", *output)
+
+	println(burner.Banner_end)
+	return 0
+}
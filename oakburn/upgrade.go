@@ -0,0 +1,47 @@
+package main
+
+import (
+	"image_burner/burner"
+	"image_burner/driver"
+)
+
+// upgrade and restore only care about devices already running Oakridge OS
+var oak_drivers = []driver.Driver{driver.Lookup("oakridge")}
+
+// run op on chosen Oakridge devices
+func oak_operation(name string, op driver.Operation, args []string) int {
+	fs, o := new_flagset(name, FLAGS_SCAN|FLAGS_OUTPUT|FLAGS_SELECT)
+	fs.Parse(args)
+	burner.Cleanup()
+	setup(o)
+
+	netlist, err := burner.Scan_networks(fs.Args(), oak_drivers)
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	devs := burner.Devices(netlist)
+	burner.Report_devices(devs, op)
+	return oak_devices(name, op, devs, o)
+}
+
+func oak_devices(name string, op driver.Operation, devs []*driver.Device, o *burner.Options) int {
+	targets := burner.Job_targets(devs, op, name, o)
+	failed := burner.Run_all(targets, op, func(d *driver.Device) error {
+		return driver.Run(d, op)
+	})
+	burner.Flush_output()
+
+	println(burner.Banner_end)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func cmd_upgrade(args []string) int {
+	return oak_operation("upgrade", driver.OP_UPGRADE, args)
+}
+
+func cmd_restore(args []string) int {
+	return oak_operation("restore", driver.OP_RESTORE, args)
+}
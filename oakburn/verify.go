@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"image_burner/burner"
+	"image_burner/util"
+	"io/ioutil"
+	"os"
+)
+
+func cmd_verify(args []string) int {
+	fs, o := new_flagset("verify", 0)
+	models_arg := fs.String("models", "all", "comma separated model `list` to check")
+	fs.Parse(args)
+	setup(o)
+
+	models, err := burner.Choose_models(*models_arg)
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	println("Checking downloaded images ...\n")
+	if bad := burner.Verify_images(models); bad > 0 {
+		fmt.Printf("\n%d images do not match, they will be downloaded again when used\n", bad)
+		return 1
+	}
+	return 0
+}
+
+func cmd_bundle(args []string) int {
+	fs, o := new_flagset("bundle", 0)
+	models_arg := fs.String("models", "all", "comma separated model `list` to bundle")
+	output := fs.String("o", "oakridge-bundle.tar", "bundle `file` to write")
+	fs.Parse(args)
+	setup(o)
+
+	models, err := burner.Choose_models(*models_arg)
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+
+	tmpdir, err := ioutil.TempDir("", "oakbundle")
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	entries, err := burner.Fetch_bundle(models, tmpdir)
+	if err != nil {
+		log.Error.Println(err)
+		return 1
+	}
+
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	if err := oakUtility.Write_bundle(*output, ids, entries); err != nil {
+		log.Error.Println(err)
+		return 1
+	}
+	fmt.Printf("\n%d files of %d models saved in %s\n", len(entries), len(models), *output)
+
+	println(burner.Banner_end)
+	return 0
+}
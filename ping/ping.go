@@ -12,6 +12,11 @@
 //	pinger.Run() // blocks until finished
 //	stats := pinger.Statistics() // get send/receive/rtt stats
 //
+// RunContext does the same until ctx is done, and WaitUntilReachable waits
+// for a host which is coming up:
+//
+//	err := ping.WaitUntilReachable(ctx, "192.168.1.1", 3, time.Minute)
+//
 // Here is an example that emulates the unix ping command:
 //
 //	pinger, err := ping.NewPinger("www.google.com")
@@ -44,12 +49,11 @@
 package ping
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
 	"net"
-	"os"
-	"os/signal"
 	"sync"
 	"syscall"
 	"time"
@@ -97,6 +101,7 @@ func NewPinger(addr string) (*Pinger, error) {
 		size:    timeSliceLength,
 
 		done: make(chan bool),
+		id:   rand.Intn(65535),
 	}, nil
 }
 
@@ -136,12 +141,14 @@ type Pinger struct {
 	OnFinish func(*Statistics)
 
 	// stop chan bool
-	done chan bool
+	done     chan bool
+	doneOnce sync.Once
 
 	ipaddr *net.IPAddr
 	addr   string
 
 	ipv4     bool
+	id       int
 	source   string
 	size     int
 	sequence int
@@ -151,6 +158,7 @@ type Pinger struct {
 type packet struct {
 	bytes  []byte
 	nbytes int
+	addr   net.Addr
 }
 
 // Packet represents a received and processed ICMP echo packet.
@@ -262,22 +270,25 @@ func (p *Pinger) Privileged() bool {
 }
 
 // Run runs the pinger. This is a blocking function that will exit when it's
-// done. If Count or Interval are not specified, it will run continuously until
-// it is interrupted.
+// done. If Count or Timeout are not specified, it will run until Stop is
+// called.
 func (p *Pinger) Run() {
-	p.run()
+	p.RunContext(context.Background())
 }
 
-func (p *Pinger) run() {
+// RunContext runs the pinger until Count replies, Timeout, Stop or ctx is
+// done. The error is from listening, e.g. no permission for ICMP, or ctx.Err().
+func (p *Pinger) RunContext(ctx context.Context) error {
 	var conn *icmp.PacketConn
+	var err error
 	if p.ipv4 {
-		if conn = p.listen(ipv4Proto[p.network], p.source); conn == nil {
-			return
-		}
+		conn, err = p.listen(ipv4Proto[p.network], p.source)
 	} else {
-		if conn = p.listen(ipv6Proto[p.network], p.source); conn == nil {
-			return
-		}
+		conn, err = p.listen(ipv6Proto[p.network], p.source)
+	}
+	if err != nil {
+		p.stop()
+		return err
 	}
 	defer conn.Close()
 	defer p.finish()
@@ -286,49 +297,51 @@ func (p *Pinger) run() {
 	recv := make(chan *packet, 5)
 	wg.Add(1)
 	go p.recvICMP(conn, recv, &wg)
+	defer wg.Wait()
 
-	err := p.sendICMP(conn)
-	if err != nil {
+	if err := p.sendICMP(conn); err != nil && p.Debug {
 		fmt.Println(err.Error())
 	}
 
-	timeout := time.NewTicker(p.Timeout)
+	timeout := time.NewTimer(p.Timeout)
+	defer timeout.Stop()
 	interval := time.NewTicker(p.Interval)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, syscall.SIGTERM)
+	defer interval.Stop()
 
 	for {
 		select {
-		case <-c:
-			close(p.done)
+		case <-ctx.Done():
+			p.stop()
+			return ctx.Err()
 		case <-p.done:
-			wg.Wait()
-			return
+			return nil
 		case <-timeout.C:
-			close(p.done)
-			wg.Wait()
-			return
+			p.stop()
+			return nil
 		case <-interval.C:
-			err = p.sendICMP(conn)
-			if err != nil {
-				fmt.Println("FATAL: ", err.Error())
+			if err := p.sendICMP(conn); err != nil && p.Debug {
+				fmt.Println(err.Error())
 			}
 		case r := <-recv:
-			err := p.processPacket(r)
-			if err != nil {
-				fmt.Println("FATAL: ", err.Error())
+			if err := p.processPacket(r); err != nil && p.Debug {
+				fmt.Println(err.Error())
 			}
-		default:
 			if p.Count > 0 && p.PacketsRecv >= p.Count {
-				close(p.done)
-				wg.Wait()
-				return
+				p.stop()
 			}
 		}
 	}
 }
 
+// Stop ends Run, it can be called from OnRecv or another goroutine
+func (p *Pinger) Stop() {
+	p.stop()
+}
+
+func (p *Pinger) stop() {
+	p.doneOnce.Do(func() { close(p.done) })
+}
+
 func (p *Pinger) finish() {
 	handler := p.OnFinish
 	if handler != nil {
@@ -391,20 +404,21 @@ func (p *Pinger) recvICMP(
 		default:
 			bytes := make([]byte, 512)
 			conn.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
-			n, _, err := conn.ReadFrom(bytes)
+			n, addr, err := conn.ReadFrom(bytes)
 			if err != nil {
-				if neterr, ok := err.(*net.OpError); ok {
-					if neterr.Timeout() {
-						// Read timeout
-						continue
-					} else {
-						close(p.done)
-						return
-					}
+				if neterr, ok := err.(*net.OpError); ok && neterr.Timeout() {
+					// Read timeout
+					continue
 				}
+				p.stop()
+				return
 			}
 
-			recv <- &packet{bytes: bytes, nbytes: n}
+			select {
+			case recv <- &packet{bytes: bytes, nbytes: n, addr: addr}:
+			case <-p.done:
+				return
+			}
 		}
 	}
 }
@@ -435,6 +449,11 @@ func (p *Pinger) processPacket(recv *packet) error {
 		return nil
 	}
 
+	if !p.fromTarget(recv.addr) {
+		// Reply to another pinger on this host
+		return nil
+	}
+
 	outPkt := &Packet{
 		Nbytes: recv.nbytes,
 		IPAddr: p.ipaddr,
@@ -442,6 +461,10 @@ func (p *Pinger) processPacket(recv *packet) error {
 
 	switch pkt := m.Body.(type) {
 	case *icmp.Echo:
+		if p.network == "ip" && pkt.ID != p.id {
+			// Reply to another pinger, unprivileged sockets only see their own
+			return nil
+		}
 		outPkt.Rtt = time.Since(bytesToTime(pkt.Data[:timeSliceLength]))
 		outPkt.Seq = pkt.Seq
 		p.PacketsRecv += 1
@@ -459,11 +482,21 @@ func (p *Pinger) processPacket(recv *packet) error {
 
         p.stop_after_cnt --
         if p.stop_after_cnt == 0 {
-	    close(p.done)
+	    p.stop()
         }
 	return nil
 }
 
+func (p *Pinger) fromTarget(addr net.Addr) bool {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP.Equal(p.ipaddr.IP)
+	case *net.UDPAddr:
+		return a.IP.Equal(p.ipaddr.IP)
+	}
+	return true
+}
+
 func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 	var typ icmp.Type
 	if p.ipv4 {
@@ -484,7 +517,7 @@ func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 	bytes, err := (&icmp.Message{
 		Type: typ, Code: 0,
 		Body: &icmp.Echo{
-			ID:   rand.Intn(65535),
+			ID:   p.id,
 			Seq:  p.sequence,
 			Data: t,
 		},
@@ -508,14 +541,12 @@ func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 	return nil
 }
 
-func (p *Pinger) listen(netProto string, source string) *icmp.PacketConn {
+func (p *Pinger) listen(netProto string, source string) (*icmp.PacketConn, error) {
 	conn, err := icmp.ListenPacket(netProto, source)
 	if err != nil {
-		fmt.Printf("Error listening for ICMP packets: %s\n", err.Error())
-		close(p.done)
-		return nil
+		return nil, fmt.Errorf("Error listening for ICMP packets: %s", err.Error())
 	}
-	return conn
+	return conn, nil
 }
 
 func byteSliceOfSize(n int) []byte {
@@ -0,0 +1,80 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned by WaitUntilReachable when the host did not
+// answer enough pings in a row in time.
+type TimeoutError struct {
+	Addr    string
+	Replies int // in a row asked for
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s did not answer %d pings in a row within %s", e.Addr, e.Replies, e.Timeout)
+}
+
+// IsTimeout tells whether err is a *TimeoutError, as opposed to not being
+// able to ping at all.
+func IsTimeout(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
+// WaitUntilReachable pings addr once a second until it answered
+// consecutiveReplies pings in a row. A lost reply starts counting over, so a
+// host still going down for a reboot does not count as up. Unprivileged ping
+// is tried first, then privileged. It returns a *TimeoutError after timeout,
+// ctx.Err() if ctx is done first, or the error of not being able to ping.
+func WaitUntilReachable(ctx context.Context, addr string, consecutiveReplies int, timeout time.Duration) error {
+	if consecutiveReplies < 1 {
+		consecutiveReplies = 1
+	}
+	wait, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	for _, privileged := range []bool{false, true} {
+		var p *Pinger
+		if p, err = NewPinger(addr); err != nil {
+			return err
+		}
+		p.SetPrivileged(privileged)
+
+		reached := false
+		inRow, last := 0, -1
+		p.OnRecv = func(pkt *Packet) {
+			switch {
+			case pkt.Seq == last:
+				return // duplicate
+			case pkt.Seq == last+1 && inRow > 0:
+				inRow++
+			default:
+				inRow = 1
+			}
+			last = pkt.Seq
+			if inRow >= consecutiveReplies {
+				reached = true
+				p.Stop()
+			}
+		}
+
+		err = p.RunContext(wait)
+		switch {
+		case reached:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case wait.Err() != nil:
+			return &TimeoutError{Addr: addr, Replies: consecutiveReplies, Timeout: timeout}
+		case err == nil:
+			// ran until the socket failed
+			return fmt.Errorf("ping %s: receive failed", addr)
+		}
+	}
+	return fmt.Errorf("cannot ping %s: %s", addr, err.Error())
+}
@@ -0,0 +1,204 @@
+package spinner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Board draws one row per job under each other, for jobs running at the
+// same time which would garble a Spinner each. Without a terminal every
+// change of stage and every result is logged as a line instead.
+type Board struct {
+	sync.Mutex
+	rows      []*Row
+	drawn     int // rows on screen, cursor is right below them
+	frame     int
+	FrameRate time.Duration
+	Output    io.Writer
+	NoTty     bool
+	runChan   chan struct{}
+	stopOnce  sync.Once
+}
+
+// Row is one job on a Board
+type Row struct {
+	board   *Board
+	name    string
+	stage   string
+	detail  string
+	start   time.Time
+	elapsed time.Duration // frozen when done
+	result  string
+	done    bool
+	failed  bool
+}
+
+func NewBoard() *Board {
+	b := &Board{
+		FrameRate: DEFAULT_FRAME_RATE,
+		Output:    os.Stdout,
+		runChan:   make(chan struct{}),
+	}
+	if !terminal.IsTerminal(int(syscall.Stdout)) {
+		b.NoTty = true
+	}
+	return b
+}
+
+// start redrawing, rows can be added before or after
+func (b *Board) Start() *Board {
+	if !b.NoTty {
+		fmt.Fprint(b.Output, Hide())
+		go b.writer()
+	}
+	return b
+}
+
+// stop redrawing, leave final state of all rows on screen
+func (b *Board) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.runChan)
+		if b.NoTty {
+			return
+		}
+		b.Lock()
+		b.draw()
+		b.Unlock()
+		fmt.Fprint(b.Output, Show())
+	})
+}
+
+// add a row named name, e.g. ip and model of a device
+func (b *Board) Add(name string) *Row {
+	r := &Row{board: b, name: name, stage: "waiting", start: time.Now()}
+	b.Lock()
+	b.rows = append(b.rows, r)
+	b.Unlock()
+	return r
+}
+
+// print a message above the board
+func (b *Board) Printf(format string, args ...interface{}) {
+	msg := strings.Trim(fmt.Sprintf(format, args...), "\n")
+	if msg == "" {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.NoTty {
+		fmt.Fprintln(b.Output, msg)
+		return
+	}
+	b.clear()
+	fmt.Fprintln(b.Output, msg)
+	b.draw()
+}
+
+func (b *Board) writer() {
+	ticker := time.NewTicker(b.FrameRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.runChan:
+			return
+		case <-ticker.C:
+			b.Lock()
+			b.frame++
+			b.draw()
+			b.Unlock()
+		}
+	}
+}
+
+// called with lock held, move back over what was drawn
+func (b *Board) clear() {
+	if b.drawn > 0 {
+		fmt.Fprintf(b.Output, "\r%s%s", MoveUp(b.drawn), ClearScreenDown())
+	}
+	b.drawn = 0
+}
+
+// called with lock held
+func (b *Board) draw() {
+	if b.drawn > 0 {
+		fmt.Fprintf(b.Output, "\r%s", MoveUp(b.drawn))
+	}
+	for _, r := range b.rows {
+		fmt.Fprintf(b.Output, "\r%s%s\n", ClearEntireLine(), r.line(b.frame))
+	}
+	b.drawn = len(b.rows)
+}
+
+// called with board lock held
+func (r *Row) line(frame int) string {
+	mark := DefaultCharset[frame%len(DefaultCharset)]
+	elapsed := time.Since(r.start)
+	text := r.detail
+	switch {
+	case r.done && r.failed:
+		mark, elapsed, text = "x", r.elapsed, r.result
+	case r.done:
+		mark, elapsed, text = "+", r.elapsed, r.result
+	}
+	return fmt.Sprintf("%s %-28s %-28s %8s %s", mark, r.name, r.stage, elapsed.Round(time.Second), text)
+}
+
+// Stage tells what the job is doing now, detail is cleared
+func (r *Row) Stage(stage string) {
+	r.set(stage, "")
+}
+
+// Detail is shown after the stage, e.g. how far a copy is
+func (r *Row) Detail(detail string) {
+	b := r.board
+	b.Lock()
+	r.detail = detail
+	b.Unlock()
+}
+
+func (r *Row) set(stage string, detail string) {
+	b := r.board
+	b.Lock()
+	defer b.Unlock()
+	changed := stage != r.stage
+	r.stage, r.detail = stage, detail
+	if changed && b.NoTty {
+		fmt.Fprintf(b.Output, "%s: %s\n", r.name, stage)
+	}
+}
+
+// Done ends the job with its result
+func (r *Row) Done(err error) {
+	b := r.board
+	b.Lock()
+	defer b.Unlock()
+	r.done, r.elapsed = true, time.Since(r.start)
+	r.result = "done"
+	if err != nil {
+		r.failed, r.result = true, "failed: "+err.Error()
+	}
+	if b.NoTty {
+		fmt.Fprintf(b.Output, "%s: %s (%s)\n", r.name, r.result, r.elapsed.Round(time.Second))
+	}
+}
+
+// Printf prints a message of this job above the board
+func (r *Row) Printf(format string, args ...interface{}) {
+	if msg := strings.Trim(fmt.Sprintf(format, args...), "\n"); msg != "" {
+		r.board.Printf("%s: %s", r.name, msg)
+	}
+}
+
+// Spinner which shows on this row instead of drawing itself
+func (r *Row) Spinner(title string) *Spinner {
+	sp := NewSpinner(title)
+	sp.row = r
+	return sp
+}
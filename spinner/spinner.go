@@ -7,6 +7,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -26,6 +27,7 @@ type Spinner struct {
 	stopOnce  sync.Once
 	Output    io.Writer
 	NoTty     bool
+	row       *Row // title is the detail of a Board row instead
 }
 
 // create spinner object
@@ -49,14 +51,43 @@ func StartNew(title string) *Spinner {
 
 // start spinner
 func (sp *Spinner) Start() *Spinner {
-    go sp.writer()
-    return sp
+	if sp.row != nil {
+		sp.row.Detail(sp.Title)
+		return sp
+	}
+	go sp.writer()
+	return sp
 }
 
 // change title
-func (sp *Spinner) SetTitle(t string){
-    sp.Title = t
-    fmt.Printf("%s\r",ClearEntireLine())
+func (sp *Spinner) SetTitle(t string) {
+	sp.Lock()
+	sp.Title = t
+	sp.Unlock()
+	if sp.row != nil {
+		sp.row.Detail(t)
+		return
+	}
+	fmt.Printf("%s\r", ClearEntireLine())
+}
+
+// show how far a copy is in the title, with rate in bytes a second
+func (sp *Spinner) SetProgress(title string, done int64, total int64, rate float64) {
+	var t string
+	if total > 0 {
+		t = fmt.Sprintf("%3d%%", done*100/total)
+	}
+	if rate > 0 {
+		eta := time.Duration(float64(total-done)/rate) * time.Second
+		t += fmt.Sprintf(" %s/s ETA %s", humanize.Bytes(uint64(rate)), eta)
+	}
+	if sp.row != nil {
+		sp.row.Detail(title + " " + t)
+		return
+	}
+	sp.Lock()
+	sp.Title = title + " " + t
+	sp.Unlock()
 }
 
 // set custom spinner frame rate
@@ -80,6 +111,10 @@ func (sp *Spinner) Stop() {
 	//prevent multiple calls
 	sp.stopOnce.Do(func() {
 		close(sp.runChan)
+		if sp.row != nil {
+			sp.row.Detail("")
+			return
+		}
 		sp.clearLine()
 	})
 }
@@ -88,7 +123,9 @@ func (sp *Spinner) Stop() {
 func (sp *Spinner) animate() {
 	var out string
 	for i := 0; i < len(sp.Charset); i++ {
+		sp.Lock()
 		out = sp.Charset[i] + " " + sp.Title
+		sp.Unlock()
 		switch {
 		case sp.Output != nil:
 			fmt.Fprint(sp.Output, out)
@@ -118,6 +155,6 @@ func (sp *Spinner) writer() {
 // workaround for Mac OS < 10 compatibility
 func (sp *Spinner) clearLine() {
 	if !sp.NoTty {
-            fmt.Printf("%s\r",ClearLineLeft())
+		fmt.Printf("%s\r", ClearLineLeft())
 	}
 }
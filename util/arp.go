@@ -0,0 +1,93 @@
+package oakUtility
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Neighbor is one resolved entry of the host arp table
+type Neighbor struct {
+	IPv4 string
+	Mac  string
+}
+
+// Read_neighbors returns resolved arp entries, /proc/net/arp on Linux,
+// output of "arp -a" elsewhere
+func Read_neighbors() ([]Neighbor, error) {
+	if runtime.GOOS == "linux" {
+		f, err := os.Open("/proc/net/arp")
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parse_proc_arp(f), nil
+	}
+
+	out, err := exec.Command("arp", "-a").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parse_arp_a(bytes.NewReader(out)), nil
+}
+
+// IP address       HW type     Flags       HW address            Mask     Device
+// 10.1.1.1         0x1         0x2         00:11:22:33:44:55     *        eth0
+func parse_proc_arp(r io.Reader) []Neighbor {
+	var ns []Neighbor
+	s := bufio.NewScanner(r)
+	s.Scan() // header
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 4 || f[2] == "0x0" {
+			continue // incomplete
+		}
+		if n, ok := new_neighbor(f[0], f[3]); ok {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// mac: ? (10.1.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]
+// windows:   10.1.1.1              00-11-22-33-44-55     dynamic
+var arp_a_re = regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+)\)?\s+(?:at\s+)?([0-9a-fA-F]{1,2}(?:[:-][0-9a-fA-F]{1,2}){5})`)
+
+func parse_arp_a(r io.Reader) []Neighbor {
+	var ns []Neighbor
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m := arp_a_re.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+		if n, ok := new_neighbor(m[1], m[2]); ok {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+func new_neighbor(ip string, mac string) (Neighbor, bool) {
+	// macOS drops leading zeros, 0:11:2:..
+	parts := strings.FieldsFunc(mac, func(r rune) bool { return r == ':' || r == '-' })
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+	hw, err := net.ParseMAC(strings.Join(parts, ":"))
+	if err != nil || net.ParseIP(ip) == nil {
+		return Neighbor{}, false
+	}
+	if hw.String() == "00:00:00:00:00:00" || hw.String() == "ff:ff:ff:ff:ff:ff" {
+		return Neighbor{}, false
+	}
+	return Neighbor{IPv4: ip, Mac: hw.String()}, true
+}
@@ -0,0 +1,206 @@
+package oakUtility
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+ * an offline bundle is a plain tar, bundle.json first, then every file
+ * stored under its url path, so it still matches when image server moves
+ */
+const (
+	BUNDLE_MANIFEST = "bundle.json"
+	BUNDLE_VERSION  = 1
+)
+
+type Bundle_manifest struct {
+	Version int               `json:"version"`
+	Created string            `json:"created"`
+	Models  []string          `json:"models"`
+	Files   map[string]string `json:"files"` // url path -> tar member
+}
+
+type Bundle struct {
+	File     string
+	Manifest Bundle_manifest
+}
+
+type Bundle_entry struct {
+	URL       string
+	Localfile string
+}
+
+// when set, every download is served from it and network is never touched
+var bundle *Bundle
+
+func Use_bundle(file string) error {
+	b, err := Open_bundle(file)
+	if err != nil {
+		return err
+	}
+	bundle = b
+	return nil
+}
+
+func Open_bundle(file string) (*Bundle, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if hdr.Name != BUNDLE_MANIFEST {
+		return nil, fmt.Errorf("%s: not a bundle, first member is %s", file, hdr.Name)
+	}
+	b := Bundle{File: file}
+	if err := json.NewDecoder(tr).Decode(&b.Manifest); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if b.Manifest.Version < 1 || b.Manifest.Version > BUNDLE_VERSION {
+		return nil, fmt.Errorf("%s: bundle version %d not supported", file, b.Manifest.Version)
+	}
+	return &b, nil
+}
+
+func bundle_key(rawurl string) string {
+	if u, err := url.Parse(rawurl); err == nil {
+		return path.Clean("/" + u.Path)
+	}
+	return path.Clean("/" + rawurl)
+}
+
+// Extract copies the file which was fetched from rawurl into w
+func (b *Bundle) Extract(rawurl string, w io.Writer) error {
+	member, ok := b.Manifest.Files[bundle_key(rawurl)]
+	if !ok {
+		return fmt.Errorf("%s not in bundle %s", bundle_key(rawurl), b.File)
+	}
+
+	f, err := os.Open(b.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s missing in bundle %s", member, b.File)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name == member {
+			_, err = io.Copy(w, tr)
+			return err
+		}
+	}
+}
+
+func bundle_extract_file(localfile string, rawurl string) error {
+	out, err := os.Create(localfile + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := bundle.Extract(rawurl, out); err != nil {
+		out.Close()
+		os.Remove(localfile + ".tmp")
+		return err
+	}
+	out.Close()
+	return os.Rename(localfile+".tmp", localfile)
+}
+
+func bundle_get_bytes(rawurl string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := bundle.Extract(rawurl, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func bundle_add_file(tw *tar.Writer, member string, localfile string, now time.Time) error {
+	in, err := os.Open(localfile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: member, Mode: 0644, Size: st.Size(), ModTime: now}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+// Write_bundle packs already downloaded files into an offline bundle
+func Write_bundle(file string, models []string, entries []Bundle_entry) error {
+	m := Bundle_manifest{
+		Version: BUNDLE_VERSION,
+		Created: time.Now().Format(time.RFC3339),
+		Models:  models,
+		Files:   map[string]string{},
+	}
+	for _, e := range entries {
+		key := bundle_key(e.URL)
+		m.Files[key] = strings.TrimPrefix(key, "/")
+	}
+	js, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	now := time.Now()
+	if err := tw.WriteHeader(&tar.Header{Name: BUNDLE_MANIFEST, Mode: 0644, Size: int64(len(js)), ModTime: now}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(js); err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	for _, e := range entries {
+		member := m.Files[bundle_key(e.URL)]
+		if done[member] {
+			continue
+		}
+		done[member] = true
+
+		if err := bundle_add_file(tw, member, e.Localfile, now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
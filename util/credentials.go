@@ -0,0 +1,155 @@
+package oakUtility
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+	"io/ioutil"
+	"os"
+)
+
+/*
+ * credentials file lists logins per driver name, tried in order before the
+ * factory default of the driver, "*" entries are tried for every driver:
+ *   {"ubnt_ap": [{"user": "ubnt", "pass": "secret"}, {"user": "admin", "key": "id_rsa"}]}
+ * a sealed file keeps the same json encrypted with a passphrase,
+ * taken from env OAK_CREDENTIALS_PASSPHRASE or asked on the terminal
+ */
+type Credentials map[string][]Login
+
+const CREDENTIALS_ANY = "*"
+
+type sealed_credentials struct {
+	Sealed int    `json:"sealed"` // format version
+	Salt   []byte `json:"salt"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+const SEALED_VERSION = 1
+
+func credentials_key(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func credentials_gcm(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := credentials_key(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal_credentials encrypts plain credentials json with passphrase
+func Seal_credentials(plain []byte, passphrase string) ([]byte, error) {
+	if _, err := Parse_credentials(plain, ""); err != nil {
+		return nil, err
+	}
+	s := sealed_credentials{Sealed: SEALED_VERSION, Salt: make([]byte, 16)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := credentials_gcm(passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Data = gcm.Seal(nil, s.Nonce, plain, nil)
+	return json.MarshalIndent(&s, "", "  ")
+}
+
+func unseal_credentials(s *sealed_credentials, passphrase string) ([]byte, error) {
+	if s.Sealed != SEALED_VERSION {
+		return nil, fmt.Errorf("sealed credentials version %d not supported", s.Sealed)
+	}
+	gcm, err := credentials_gcm(passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("bad sealed credentials")
+	}
+	plain, err := gcm.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or damaged credentials")
+	}
+	return plain, nil
+}
+
+// Parse_credentials reads plain or sealed json, passphrase is only asked for sealed one
+func Parse_credentials(data []byte, passphrase string) (Credentials, error) {
+	var s sealed_credentials
+	if err := json.Unmarshal(data, &s); err == nil && s.Sealed != 0 {
+		if passphrase == "" {
+			if passphrase, err = Credentials_passphrase("Passphrase of credentials: "); err != nil {
+				return nil, err
+			}
+		}
+		if data, err = unseal_credentials(&s, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+	for name, list := range creds {
+		for _, l := range list {
+			if l.User == "" {
+				return nil, fmt.Errorf("%s: login without user", name)
+			}
+		}
+	}
+	return creds, nil
+}
+
+// Load_credentials reads credentials file, "" gives none
+func Load_credentials(file string) (Credentials, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := Parse_credentials(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return creds, nil
+}
+
+// Credentials_passphrase from env OAK_CREDENTIALS_PASSPHRASE, otherwise asked without echo
+func Credentials_passphrase(prompt string) (string, error) {
+	if p := os.Getenv("OAK_CREDENTIALS_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("credentials are sealed, set OAK_CREDENTIALS_PASSPHRASE")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(p), nil
+}
+
+// For returns logins of driver name, its own ones before "*" ones
+func (creds Credentials) For(name string) []Login {
+	return append(append([]Login{}, creds[name]...), creds[CREDENTIALS_ANY]...)
+}
@@ -2,23 +2,65 @@ package oakUtility
 
 /*
  * this is from https://golangcode.com/download-a-file-from-a-url/
+ * extended to resume a partial <file>.tmp with http Range and retry on flaky links
  */
 import (
+	"context"
 	"fmt"
 	"github.com/dustin/go-humanize"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	Download_retries  = 5                // give up after so many attempts without any progress
+	Download_timeout  = 10 * time.Minute // one attempt, a resumed attempt starts over
+	Stale_lock_age    = 2 * time.Minute  // a .lock not refreshed for this long is left by a dead process
+	Download_progress = true             // print bytes downloaded, off while a progress board is drawn
+)
+
+// connect fails fast on an offline site instead of waiting for the os tcp timeout
+var dial_timeout = 10 * time.Second
+
+var http_transport = &http.Transport{
+	Proxy:               http.ProxyFromEnvironment,
+	DialContext:         (&net.Dialer{Timeout: dial_timeout}).DialContext,
+	TLSHandshakeTimeout: dial_timeout,
+}
+
+// for small files like manifest, images go with Download_timeout per attempt
+var http_client = &http.Client{Timeout: time.Minute, Transport: http_transport}
+var download_client = &http.Client{Transport: http_transport}
+
 // WriteCounter counts the number of bytes written to it. It implements to the io.Writer
 // interface and we can pass this into io.TeeReader() which will report progress on each
 // write cycle.
 type WriteCounter struct {
 	Total      uint64
 	Prefix_txt string
+	Rep        Reporter // shows progress instead of stdout, if set
+}
+
+// Reporter takes progress and messages of a download in place of stdout,
+// e.g. the progress board row of the device an image is for
+type Reporter interface {
+	Detail(detail string)
+	Printf(format string, args ...interface{})
+}
+
+// say prints a message of a download on rep, on stdout without one
+func say(rep Reporter, format string, args ...interface{}) {
+	if rep != nil {
+		rep.Printf(format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
 }
 
 func (wc *WriteCounter) Write(p []byte) (int, error) {
@@ -29,6 +71,10 @@ func (wc *WriteCounter) Write(p []byte) (int, error) {
 }
 
 func (wc WriteCounter) PrintProgress() {
+	if wc.Rep != nil {
+		wc.Rep.Detail(fmt.Sprintf("%s%s", wc.Prefix_txt, humanize.Bytes(wc.Total)))
+		return
+	}
 	// Clear the line by using a character return to go back to the start and remove
 	// the remaining characters by filling it with spaces
 	fmt.Printf("\r%s", strings.Repeat(" ", len(wc.Prefix_txt)+16))
@@ -38,61 +84,309 @@ func (wc WriteCounter) PrintProgress() {
 	fmt.Printf("\r%s%s (%d)", wc.Prefix_txt, humanize.Bytes(wc.Total), wc.Total)
 }
 
-func On_demand_download(localfile string, url string) error {
-	if _, err := os.Stat(localfile); os.IsNotExist(err) {
-		if _, err := os.Stat(localfile + ".tmp"); os.IsNotExist(err) {
-			if err := DownloadFile(localfile, url, true, "Downloading "+localfile+"... "); err != nil {
-				return err
-			}
-		} else {
-			_, err := os.Stat(localfile)
-			for _, err = os.Stat(localfile); os.IsNotExist(err); {
-				time.Sleep(10 * time.Second)
-				fmt.Printf("wait for %s\n", localfile)
-				_, err = os.Stat(localfile)
+var file_locks = map[string]*sync.Mutex{}
+var file_locks_lock sync.Mutex
+
+// serialize goroutines working on the same local file
+func lock_file(localfile string) func() {
+	file_locks_lock.Lock()
+	l, ok := file_locks[localfile]
+	if !ok {
+		l = &sync.Mutex{}
+		file_locks[localfile] = l
+	}
+	file_locks_lock.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+/*
+ * <file>.lock keeps other processes off a download in progress, the owner
+ * touches it while working, so one which stops changing belongs to a dead process
+ */
+func acquire_lock(lockfile string, rep Reporter) (func(), error) {
+	for {
+		f, err := os.OpenFile(lockfile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		st, err := os.Stat(lockfile)
+		if err == nil && time.Since(st.ModTime()) > Stale_lock_age {
+			say(rep, "remove stale %s\n", lockfile)
+			os.Remove(lockfile)
+			continue
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(Stale_lock_age / 4)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				os.Chtimes(lockfile, now, now)
 			}
 		}
+	}()
+	return func() {
+		close(done)
+		os.Remove(lockfile)
+	}, nil
+}
+
+func On_demand_download(localfile string, url string) error {
+	unlock := lock_file(localfile)
+	defer unlock()
+	return on_demand_download(localfile, url, nil)
+}
+
+// caller holds lock_file(localfile)
+func on_demand_download(localfile string, url string, rep Reporter) error {
+	if _, err := os.Stat(localfile); err == nil {
+		return nil
+	}
+
+	if bundle != nil {
+		return bundle_extract_file(localfile, url)
+	}
+
+	release, err := acquire_lock(localfile+".lock", rep)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// another process may have finished it while we wait for the lock
+	if _, err := os.Stat(localfile); err == nil {
+		return nil
+	}
+
+	return download_from_servers(localfile, url, Download_progress, rep)
+}
+
+// On_demand_fetch is On_demand_download for small files like version
+// stamps: one attempt per server, no retry, no progress
+func On_demand_fetch(localfile string, url string) error {
+	unlock := lock_file(localfile)
+	defer unlock()
+	if _, err := os.Stat(localfile); err == nil {
+		return nil
+	}
+	if bundle != nil {
+		return bundle_extract_file(localfile, url)
+	}
+	urls := Image_urls(url)
+	if len(urls) == 0 {
+		return fmt.Errorf("%s: no image server", url)
+	}
+	var err error
+	for _, u := range urls {
+		if err = fetch_once(localfile, u); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func fetch_once(localfile string, url string) error {
+	tmpfile := localfile + ".tmp"
+	os.Remove(tmpfile)
+	if _, err := download_once(tmpfile, url, false, "", nil); err != nil {
+		os.Remove(tmpfile)
+		return fmt.Errorf("download %s: %s", url, err.Error())
+	}
+	return os.Rename(tmpfile, localfile)
+}
+
+// Download_from_servers fetches ref from image server, then mirrors in order
+func Download_from_servers(localfile string, ref string, show_progress bool) error {
+	return download_from_servers(localfile, ref, show_progress, nil)
+}
+
+func download_from_servers(localfile string, ref string, show_progress bool, rep Reporter) error {
+	urls := Image_urls(ref)
+	if len(urls) == 0 {
+		return fmt.Errorf("%s: no image server", ref)
+	}
+	var err error
+	for _, u := range urls {
+		if err = download_file(localfile, u, show_progress, "Downloading "+localfile+"... ", rep); err == nil {
+			return nil
+		}
+		say(rep, "%s\n", err.Error())
+	}
+	return err
+}
+
+type http_status_error struct {
+	code   int
+	status string
+}
+
+func (e *http_status_error) Error() string {
+	return e.status
+}
+
+// 4xx or missing local file will not get better by asking again
+func retryable(err error) bool {
+	if _, ok := err.(*os.PathError); ok {
+		return false
+	}
+	if e, ok := err.(*http_status_error); ok {
+		return e.code >= 500 || e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests
 	}
-	return nil
+	return true
 }
 
 // DownloadFile will download a url to a local file. It's efficient because it will
 // write as it downloads and not load the whole file into memory. We pass an io.TeeReader
 // into Copy() to report progress on the download.
+// A <file>.tmp left by an interrupted download is resumed, not started over.
 func DownloadFile(filepath string, url string, progress bool, prefix string) error {
+	return download_file(filepath, url, progress, prefix, nil)
+}
+
+func download_file(filepath string, url string, progress bool, prefix string, rep Reporter) error {
+
+	tmpfile := filepath + ".tmp"
+	failed := 0
+	furthest := tmp_size(tmpfile)
+	for {
+		_, err := download_once(tmpfile, url, progress, prefix, rep)
+		if err == nil {
+			break
+		}
+		// it moves only if the file grew past where it ever was, a server
+		// which ignores Range starts over each time and would never give up
+		if size := tmp_size(tmpfile); size > furthest {
+			furthest = size
+			failed = 0
+		}
+		failed++
+		if failed >= Download_retries || !retryable(err) {
+			return fmt.Errorf("download %s: %s", url, err.Error())
+		}
+		backoff := time.Second << uint(failed)
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+		say(rep, "\n%s: %s, retry in %v\n", url, err.Error(), backoff)
+		time.Sleep(backoff)
+	}
+
+	return os.Rename(tmpfile, filepath)
+}
+
+func tmp_size(tmpfile string) int64 {
+	if st, err := os.Stat(tmpfile); err == nil {
+		return st.Size()
+	}
+	return 0
+}
 
-	// Create the file, but give it a tmp file extension, this means we won't overwrite a
-	// file until it's downloaded, but we'll remove the tmp extension once downloaded.
-	out, err := os.Create(filepath + ".tmp")
+// fetch what tmpfile is still missing, return bytes got in this attempt.
+// progress goes to rep if there is one, else to stdout if progress is set
+func download_once(tmpfile string, url string, progress bool, prefix string, rep Reporter) (int64, error) {
+
+	out, err := os.OpenFile(tmpfile, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer out.Close()
+
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if is_file_url(url) {
+		return copy_local(out, offset, file_url_path(url))
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Download_timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
 	}
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := download_client.Do(req)
 	if err != nil {
-		out.Close()
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if progress == true {
-		counter := &WriteCounter{Prefix_txt: prefix}
-		_, err = io.Copy(out, io.TeeReader(resp.Body, counter))
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// resume where we are
+	case http.StatusOK:
+		// server ignores Range, start over
+		if err := out.Truncate(0); err != nil {
+			return 0, err
+		}
+		if offset, err = out.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// tmp is bigger than the file now on server, it is some other file
+		out.Truncate(0)
+		return 0, fmt.Errorf("%s changed on server, restart", url)
+	default:
+		return 0, &http_status_error{code: resp.StatusCode, status: resp.Status}
+	}
+
+	var n int64
+	if rep != nil {
+		counter := &WriteCounter{Total: uint64(offset), Prefix_txt: prefix, Rep: rep}
+		n, err = io.Copy(out, io.TeeReader(resp.Body, counter))
+	} else if progress == true {
+		counter := &WriteCounter{Total: uint64(offset), Prefix_txt: prefix}
+		n, err = io.Copy(out, io.TeeReader(resp.Body, counter))
 		fmt.Print("\n")
 	} else {
-		_, err = io.Copy(out, resp.Body)
+		n, err = io.Copy(out, resp.Body)
 	}
 	if err != nil {
-		out.Close()
-		return err
+		return n, err
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("short read %d of %d bytes", n, resp.ContentLength)
 	}
+	return n, nil
+}
 
-	out.Close()
-	err = os.Rename(filepath+".tmp", filepath)
+// file:// source, e.g. a mounted usb stick or a test tree
+func copy_local(out *os.File, offset int64, file string) (int64, error) {
+	in, err := os.Open(file)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer in.Close()
 
-	return nil
+	st, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if offset > st.Size() {
+		out.Truncate(0)
+		return 0, fmt.Errorf("%s changed, restart", file)
+	}
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(out, in)
 }
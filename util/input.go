@@ -0,0 +1,24 @@
+package oakUtility
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Confirm asks a y/N question on stdin, anything but yes is no
+func Confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	r := bufio.NewReader(os.Stdin)
+	input, err := r.ReadString('\n')
+	if err != nil && input == "" {
+		println()
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package oakUtility
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+ * host keys are pinned on first contact, keyed by mac plus ip since devices
+ * move between dhcp addresses and addresses between devices. one line each:
+ *   <mac> <ip> <key type> <base64 key>
+ * the mac is only known after login, so detection checks once it has read it
+ * and later connections to a known device check during the handshake
+ */
+const (
+	HOSTKEY_OFF    = "off"
+	HOSTKEY_WARN   = "warn"   // report mismatch, carry on
+	HOSTKEY_STRICT = "strict" // refuse hosts whose key changed
+)
+
+var Host_key_policy = HOSTKEY_WARN
+
+type Known_hosts struct {
+	file string
+	lock sync.Mutex
+	keys map[string]ssh.PublicKey // "mac ip" to key
+}
+
+var known_hosts *Known_hosts
+
+type Host_key_mismatch struct {
+	Mac  string
+	IPv4 string
+	Want string // fingerprints
+	Got  string
+}
+
+func (e *Host_key_mismatch) Error() string {
+	return fmt.Sprintf("host key of %s %s changed, pinned %s, got %s", e.Mac, e.IPv4, e.Want, e.Got)
+}
+
+func Default_known_hosts_file() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".oakridge", "known_hosts")
+}
+
+func known_hosts_key(mac string, ip string) string {
+	return strings.ToLower(mac) + " " + ip
+}
+
+// Open_known_hosts reads file, it is created on first pin
+func Open_known_hosts(file string) (*Known_hosts, error) {
+	k := &Known_hosts{file: file, keys: map[string]ssh.PublicKey{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.SplitN(line, " ", 3)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("%s:%d: bad line", file, n)
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(f[2]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, n, err.Error())
+		}
+		k.keys[known_hosts_key(f[0], f[1])] = key
+	}
+	return k, nil
+}
+
+// called with lock held
+func (k *Known_hosts) save() error {
+	var lines []string
+	for id, key := range k.keys {
+		lines = append(lines, id+" "+strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
+	}
+	sort.Strings(lines)
+	if err := os.MkdirAll(filepath.Dir(k.file), 0700); err != nil {
+		return err
+	}
+	tmp := k.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.file)
+}
+
+// Check pins key of an unknown host, returns *Host_key_mismatch if it differs from the pinned one
+func (k *Known_hosts) Check(mac string, ip string, key ssh.PublicKey) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	id := known_hosts_key(mac, ip)
+	pinned, ok := k.keys[id]
+	if !ok {
+		k.keys[id] = key
+		return k.save()
+	}
+	if !bytes.Equal(pinned.Marshal(), key.Marshal()) {
+		return &Host_key_mismatch{Mac: mac, IPv4: ip, Want: ssh.FingerprintSHA256(pinned), Got: ssh.FingerprintSHA256(key)}
+	}
+	return nil
+}
+
+// Forget drops the pin of a host, its next key is trusted again
+func (k *Known_hosts) Forget(mac string, ip string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	id := known_hosts_key(mac, ip)
+	if _, ok := k.keys[id]; !ok {
+		return nil
+	}
+	delete(k.keys, id)
+	return k.save()
+}
+
+// Use_known_hosts sets store and policy, "" file is the default one
+func Use_known_hosts(file string, policy string) error {
+	switch policy {
+	case HOSTKEY_OFF:
+		known_hosts = nil
+		Host_key_policy = policy
+		return nil
+	case HOSTKEY_WARN, HOSTKEY_STRICT:
+	default:
+		return fmt.Errorf("host key policy must be %s, %s or %s", HOSTKEY_OFF, HOSTKEY_WARN, HOSTKEY_STRICT)
+	}
+	if file == "" {
+		file = Default_known_hosts_file()
+	}
+	k, err := Open_known_hosts(file)
+	if err != nil {
+		return err
+	}
+	known_hosts, Host_key_policy = k, policy
+	return nil
+}
+
+// Verify_host_key applies policy to key of host, an error means do not trust it
+func Verify_host_key(mac string, ip string, key ssh.PublicKey) error {
+	if known_hosts == nil || mac == "" || key == nil {
+		return nil
+	}
+	err := known_hosts.Check(mac, ip, key)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Host_key_mismatch); ok && Host_key_policy == HOSTKEY_STRICT {
+		return err
+	}
+	// mismatch under warn, or pin not saved
+	fmt.Fprintf(os.Stderr, "WARNING: %s\n", err.Error())
+	return nil
+}
+
+// Forget_host_key after the firmware of host changed, which regenerates its keys
+func Forget_host_key(mac string, ip string) error {
+	if known_hosts == nil || mac == "" {
+		return nil
+	}
+	return known_hosts.Forget(mac, ip)
+}
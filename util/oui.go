@@ -0,0 +1,68 @@
+package oakUtility
+
+import (
+	"strings"
+	"sync"
+)
+
+// first 3 bytes of mac to vendor, only vendors we flash. Converted Oakridge
+// devices keep the mac of their hardware vendor, so they match here as that
+// vendor. QTS and Oakridge branded prefixes are not listed until confirmed
+// against the IEEE registry, so the arp scan probes hosts of unknown vendor
+// too; sites add prefixes with the catalog "oui" map.
+var oui_table = map[string]string{
+	"00:15:6d": "Ubiquiti",
+	"00:27:22": "Ubiquiti",
+	"04:18:d6": "Ubiquiti",
+	"18:e8:29": "Ubiquiti",
+	"24:5a:4c": "Ubiquiti",
+	"24:a4:3c": "Ubiquiti",
+	"44:d9:e7": "Ubiquiti",
+	"60:22:32": "Ubiquiti",
+	"68:72:51": "Ubiquiti",
+	"68:d7:9a": "Ubiquiti",
+	"70:a7:41": "Ubiquiti",
+	"74:83:c2": "Ubiquiti",
+	"74:ac:b9": "Ubiquiti",
+	"78:45:58": "Ubiquiti",
+	"78:8a:20": "Ubiquiti",
+	"80:2a:a8": "Ubiquiti",
+	"94:2a:6f": "Ubiquiti",
+	"ac:8b:a9": "Ubiquiti",
+	"b4:fb:e4": "Ubiquiti",
+	"d0:21:f9": "Ubiquiti",
+	"dc:9f:db": "Ubiquiti",
+	"e0:63:da": "Ubiquiti",
+	"e4:38:83": "Ubiquiti",
+	"f0:9f:c2": "Ubiquiti",
+	"f4:92:bf": "Ubiquiti",
+	"fc:ec:da": "Ubiquiti",
+	"00:03:0f": "DCN",
+}
+var oui_lock sync.RWMutex
+
+// Add_oui adds or overrides prefixes, keys like "80:2a:a8" or "802AA8"
+func Add_oui(table map[string]string) {
+	oui_lock.Lock()
+	defer oui_lock.Unlock()
+	for prefix, vendor := range table {
+		if p := oui_prefix(prefix); p != "" {
+			oui_table[p] = vendor
+		}
+	}
+}
+
+func oui_prefix(mac string) string {
+	hex := strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+	if len(hex) < 6 {
+		return ""
+	}
+	return hex[0:2] + ":" + hex[2:4] + ":" + hex[4:6]
+}
+
+// Oui_vendor returns vendor of mac, "" if not one of ours
+func Oui_vendor(mac string) string {
+	oui_lock.RLock()
+	defer oui_lock.RUnlock()
+	return oui_table[oui_prefix(mac)]
+}
@@ -0,0 +1,60 @@
+package oakUtility
+
+import (
+	"sync"
+	"time"
+)
+
+// Pool runs jobs on at most workers goroutines, and starts no more than
+// per_sec jobs a second if per_sec > 0. Submit blocks while all are busy.
+type Pool struct {
+	jobs   chan func()
+	batch  sync.WaitGroup
+	ticker *time.Ticker
+}
+
+func New_Pool(workers int, per_sec int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{jobs: make(chan func())}
+	if per_sec > 0 {
+		interval := time.Second / time.Duration(per_sec)
+		if interval <= 0 {
+			interval = time.Nanosecond
+		}
+		p.ticker = time.NewTicker(interval)
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *Pool) worker() {
+	for job := range p.jobs {
+		job()
+		p.batch.Done()
+	}
+}
+
+func (p *Pool) Submit(job func()) {
+	p.batch.Add(1)
+	if p.ticker != nil {
+		<-p.ticker.C
+	}
+	p.jobs <- job
+}
+
+// Wait for all submitted jobs to finish
+func (p *Pool) Wait() {
+	p.batch.Wait()
+}
+
+// Close stops workers, no Submit after it
+func (p *Pool) Close() {
+	close(p.jobs)
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+}
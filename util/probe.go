@@ -0,0 +1,21 @@
+package oakUtility
+
+import (
+	"net"
+	"time"
+)
+
+// Probe_port tries a tcp connect to host on each port in order, returns the
+// first open one or "" if none answers within timeout. Far cheaper than an
+// ssh handshake, so dead hosts are dropped before trying credentials.
+func Probe_port(host string, ports []string, timeout time.Duration) string {
+	for _, port := range ports {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), timeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		return port
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+package oakUtility
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+ * upload keeps going only on what the device acknowledged: scp sink acks
+ * every step with 0, or 1/2 and a message. devices without scp get sftp,
+ * then the file is hashed on the device and compared with what was sent
+ */
+
+var Sftp_fallback = true // try sftp where scp fails
+
+// No_remote_sum is a copy which arrived, but the device has nothing to hash
+// it with, only scp acks vouch for it
+type No_remote_sum struct {
+	IPv4   string
+	Remote string
+}
+
+func (e *No_remote_sum) Error() string {
+	return fmt.Sprintf("%s has no sha256sum or md5sum, checksum of %s skipped", e.IPv4, e.Remote)
+}
+
+// hashes of what was sent, the device has one of them
+type upload_sums struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+}
+
+func new_upload_sums() *upload_sums {
+	return &upload_sums{sha256: sha256.New(), md5: md5.New()}
+}
+
+func (u *upload_sums) Write(p []byte) (int, error) {
+	u.sha256.Write(p)
+	u.md5.Write(p)
+	return len(p), nil
+}
+
+// Progress is told how a copy goes, rate is bytes a second so far
+type Progress func(sent int64, total int64, rate float64)
+
+// counts like WriteCounter, but tells Progress at most every Progress_interval
+type progress_counter struct {
+	sent     int64
+	total    int64
+	start    time.Time
+	last     time.Time
+	progress Progress
+}
+
+var Progress_interval = 200 * time.Millisecond
+
+func new_progress_counter(total int64, progress Progress) *progress_counter {
+	now := time.Now()
+	return &progress_counter{total: total, start: now, last: now, progress: progress}
+}
+
+func (pc *progress_counter) Write(p []byte) (int, error) {
+	pc.sent += int64(len(p))
+	if pc.progress == nil {
+		return len(p), nil
+	}
+	now := time.Now()
+	if now.Sub(pc.last) >= Progress_interval || pc.sent == pc.total {
+		pc.last = now
+		rate := 0.0
+		if elapsed := now.Sub(pc.start).Seconds(); elapsed > 0 {
+			rate = float64(pc.sent) / elapsed
+		}
+		pc.progress(pc.sent, pc.total, rate)
+	}
+	return len(p), nil
+}
+
+func shell_quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Scp copies local to remote on the device and checks it arrived intact,
+// progress may be nil. *No_remote_sum if it could not be checked.
+func (c *SSHClient) Scp(local string, remote string, permission string, progress Progress) (int64, error) {
+	if c.client == nil {
+		return 0, fmt.Errorf("%s@%s:%s NOT connected", c.User, c.IPv4, c.Port)
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	sums := new_upload_sums()
+	sent := io.MultiWriter(sums, new_progress_counter(stat.Size(), progress))
+	n, err := c.scp_send(io.TeeReader(f, sent), stat.Size(), remote, permission)
+	if err != nil && !Sftp_fallback {
+		return n, fmt.Errorf("copy %s to %s: %s", local, c.IPv4, err.Error())
+	}
+	if err != nil {
+		scp_err := err
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		sums = new_upload_sums()
+		sent = io.MultiWriter(sums, new_progress_counter(stat.Size(), progress))
+		n, err = c.sftp_send(io.TeeReader(f, sent), remote, permission)
+		if err != nil {
+			return n, fmt.Errorf("copy %s to %s: %s, sftp: %s", local, c.IPv4, scp_err.Error(), err.Error())
+		}
+	}
+	if n != stat.Size() {
+		return n, fmt.Errorf("copy %s to %s: sent %d of %d bytes", local, c.IPv4, n, stat.Size())
+	}
+	return n, c.check_remote_sum(remote, sums)
+}
+
+// read one scp ack
+func scp_ack(r *bufio.Reader, stderr *bytes.Buffer) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("scp: %s", msg)
+		}
+		return fmt.Errorf("scp: %s", err.Error())
+	}
+	switch b {
+	case 0:
+		return nil
+	case 1, 2:
+		msg, _ := r.ReadString('\n')
+		return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+	}
+	return fmt.Errorf("scp: unexpected reply %q", b)
+}
+
+func (c *SSHClient) scp_send(r io.Reader, size int64, remote string, permission string) (int64, error) {
+	s, err := c.client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	w, err := s.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+	out, err := s.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	var stderr bytes.Buffer
+	s.Stderr = &stderr
+	// full path as target, a missing directory is an error instead of a file named like it
+	if err := s.Start("scp -t " + shell_quote(remote)); err != nil {
+		return 0, err
+	}
+	acks := bufio.NewReader(out)
+
+	if err := scp_ack(acks, &stderr); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(w, "C%s %d %s\n", permission, size, path.Base(remote))
+	if err := scp_ack(acks, &stderr); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return n, err
+	}
+	w.Write([]byte{0})
+	if err := scp_ack(acks, &stderr); err != nil {
+		return n, err
+	}
+	w.Close()
+	if err := s.Wait(); err != nil {
+		return n, fmt.Errorf("scp: %s %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return n, nil
+}
+
+const remote_sum_cmd = `if command -v sha256sum >/dev/null 2>&1; then sha256sum %[1]s; ` +
+	`elif command -v md5sum >/dev/null 2>&1; then md5sum %[1]s; else echo nosum; fi`
+
+// hash remote on the device with whatever it has and compare
+func (c *SSHClient) check_remote_sum(remote string, sums *upload_sums) error {
+	s, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	buf, err := s.CombinedOutput(fmt.Sprintf(remote_sum_cmd, shell_quote(remote)))
+	out := strings.TrimSpace(string(buf))
+	if err != nil {
+		return fmt.Errorf("checksum %s on %s: %s %s", remote, c.IPv4, err.Error(), out)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 || fields[0] == "nosum" {
+		return &No_remote_sum{IPv4: c.IPv4, Remote: remote}
+	}
+	got := strings.ToLower(fields[0])
+	var want string
+	switch len(got) {
+	case sha256.Size * 2:
+		want = hex.EncodeToString(sums.sha256.Sum(nil))
+	case md5.Size * 2:
+		want = hex.EncodeToString(sums.md5.Sum(nil))
+	default:
+		return fmt.Errorf("checksum %s on %s: unexpected output %q", remote, c.IPv4, out)
+	}
+	if got != want {
+		return fmt.Errorf("checksum %s on %s: got %s, sent %s", remote, c.IPv4, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package oakUtility
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*
+ * catalog keeps image locations relative to an image server, so the whole
+ * set can move to an internal mirror, a local http server or a file:// tree.
+ * precedence: flag > env OAK_IMAGE_SERVER/OAK_IMAGE_MIRRORS > config file > catalog
+ */
+type Config struct {
+	Image_server string   `json:"image_server,omitempty"`
+	Mirrors      []string `json:"mirrors,omitempty"`     // tried in order when image server fails
+	Credentials  string   `json:"credentials,omitempty"` // credentials file, see Load_credentials
+}
+
+func Default_config_file() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".oakridge", "config.json")
+}
+
+// Load_config reads file, "" reads the default one which may be absent
+func Load_config(file string) (Config, error) {
+	var c Config
+	must_exist := file != ""
+	if file == "" {
+		file = Default_config_file()
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) && !must_exist {
+			return c, nil
+		}
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return c, nil
+}
+
+var catalog_server string // default from catalog
+var image_server string   // given by flag, env or config, "" is the catalog one
+var image_mirrors []string
+
+func Set_catalog_server(server string) {
+	catalog_server = server
+}
+
+// Configure_image_servers applies flag values on top of env and config file
+func Configure_image_servers(server string, mirrors string, cfg Config) error {
+	if server == "" {
+		server = os.Getenv("OAK_IMAGE_SERVER")
+	}
+	if server == "" {
+		server = cfg.Image_server
+	}
+	if mirrors == "" {
+		mirrors = os.Getenv("OAK_IMAGE_MIRRORS")
+	}
+	list := cfg.Mirrors
+	if mirrors != "" {
+		list = strings.Split(mirrors, ",")
+	}
+
+	server = strings.TrimSpace(server)
+	if server != "" {
+		if _, err := url.Parse(server); err != nil {
+			return fmt.Errorf("image server %s: %s", server, err.Error())
+		}
+	}
+	image_server = server
+	image_mirrors = nil
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := url.Parse(s); err != nil {
+			return fmt.Errorf("image server %s: %s", s, err.Error())
+		}
+		image_mirrors = append(image_mirrors, s)
+	}
+	return nil
+}
+
+// Credentials_file picks flag value, then env OAK_CREDENTIALS, then config file
+func Credentials_file(file string, cfg Config) string {
+	if file == "" {
+		file = os.Getenv("OAK_CREDENTIALS")
+	}
+	if file == "" {
+		file = cfg.Credentials
+	}
+	return file
+}
+
+// Image_servers is the main server, the catalog one unless given, then the mirrors
+func Image_servers() []string {
+	main := image_server
+	if main == "" {
+		main = catalog_server
+	}
+	return append([]string{main}, image_mirrors...)
+}
+
+func is_absolute(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && u.Scheme != ""
+}
+
+// Image_urls returns where ref can be fetched from, in order to try
+func Image_urls(ref string) []string {
+	if is_absolute(ref) {
+		return []string{ref}
+	}
+	var urls []string
+	for _, s := range Image_servers() {
+		if s == "" {
+			continue
+		}
+		if !strings.HasSuffix(s, "/") {
+			s += "/"
+		}
+		base, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+		rel, err := url.Parse(strings.TrimPrefix(ref, "/"))
+		if err != nil {
+			continue
+		}
+		urls = append(urls, base.ResolveReference(rel).String())
+	}
+	return urls
+}
+
+func is_file_url(rawurl string) bool {
+	return strings.HasPrefix(rawurl, "file://")
+}
+
+func file_url_path(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return strings.TrimPrefix(rawurl, "file://")
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/") // file:///C:/images
+	}
+	return filepath.FromSlash(p)
+}
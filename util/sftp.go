@@ -0,0 +1,182 @@
+package oakUtility
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+/*
+ * just enough sftp (version 3, draft-ietf-secsh-filexfer-02) to write one
+ * file, for devices which have sftp-server but no scp
+ */
+const (
+	ssh_fxp_init    = 1
+	ssh_fxp_version = 2
+	ssh_fxp_open    = 3
+	ssh_fxp_close   = 4
+	ssh_fxp_write   = 6
+	ssh_fxp_status  = 101
+	ssh_fxp_handle  = 102
+
+	ssh_fxf_write = 0x02
+	ssh_fxf_creat = 0x08
+	ssh_fxf_trunc = 0x10
+
+	ssh_filexfer_attr_permissions = 0x04
+
+	sftp_chunk = 32 * 1024
+)
+
+type sftp_conn struct {
+	w  io.Writer
+	r  io.Reader
+	id uint32
+}
+
+func sftp_uint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func sftp_string(b []byte, s []byte) []byte {
+	return append(sftp_uint32(b, uint32(len(s))), s...)
+}
+
+func (s *sftp_conn) send(typ byte, payload []byte) error {
+	pkt := sftp_uint32(nil, uint32(len(payload)+1))
+	pkt = append(pkt, typ)
+	_, err := s.w.Write(append(pkt, payload...))
+	return err
+}
+
+func (s *sftp_conn) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > 256*1024 {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	payload := make([]byte, n-1)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], payload, nil
+}
+
+// send a request with a fresh id, return reply payload after the id
+func (s *sftp_conn) request(typ byte, body []byte) (byte, []byte, error) {
+	s.id++
+	if err := s.send(typ, append(sftp_uint32(nil, s.id), body...)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, payload, err := s.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != s.id {
+		return 0, nil, fmt.Errorf("sftp: reply out of order")
+	}
+	return rtyp, payload[4:], nil
+}
+
+// status reply to error, nil for SSH_FX_OK
+func sftp_status(typ byte, payload []byte) error {
+	if typ != ssh_fxp_status || len(payload) < 4 {
+		return fmt.Errorf("sftp: unexpected reply %d", typ)
+	}
+	code := binary.BigEndian.Uint32(payload)
+	if code == 0 {
+		return nil
+	}
+	msg := ""
+	if len(payload) >= 8 {
+		n := binary.BigEndian.Uint32(payload[4:])
+		if int(n) <= len(payload)-8 {
+			msg = string(payload[8 : 8+n])
+		}
+	}
+	return fmt.Errorf("sftp: status %d %s", code, msg)
+}
+
+func (s *sftp_conn) status_request(typ byte, body []byte) error {
+	rtyp, payload, err := s.request(typ, body)
+	if err != nil {
+		return err
+	}
+	return sftp_status(rtyp, payload)
+}
+
+func (c *SSHClient) sftp_send(r io.Reader, remote string, permission string) (int64, error) {
+	perm, err := strconv.ParseUint(permission, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad permission %q", permission)
+	}
+	s, err := c.client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	w, err := s.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+	out, err := s.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.RequestSubsystem("sftp"); err != nil {
+		return 0, err
+	}
+	sc := &sftp_conn{w: w, r: out}
+
+	if err := sc.send(ssh_fxp_init, sftp_uint32(nil, 3)); err != nil {
+		return 0, err
+	}
+	if typ, _, err := sc.recv(); err != nil {
+		return 0, err
+	} else if typ != ssh_fxp_version {
+		return 0, fmt.Errorf("sftp: unexpected reply %d to init", typ)
+	}
+
+	body := sftp_string(nil, []byte(remote))
+	body = sftp_uint32(body, ssh_fxf_write|ssh_fxf_creat|ssh_fxf_trunc)
+	body = sftp_uint32(body, ssh_filexfer_attr_permissions)
+	body = sftp_uint32(body, uint32(perm))
+	typ, payload, err := sc.request(ssh_fxp_open, body)
+	if err != nil {
+		return 0, err
+	}
+	if typ != ssh_fxp_handle {
+		return 0, sftp_status(typ, payload)
+	}
+	if len(payload) < 4 || int(binary.BigEndian.Uint32(payload)) > len(payload)-4 {
+		return 0, fmt.Errorf("sftp: bad handle")
+	}
+	handle := payload[4 : 4+binary.BigEndian.Uint32(payload)]
+
+	var n int64
+	buf := make([]byte, sftp_chunk)
+	for {
+		m, rerr := io.ReadFull(r, buf)
+		if m > 0 {
+			body := sftp_string(nil, handle)
+			body = append(body, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32))
+			body = sftp_uint32(body, uint32(n))
+			body = sftp_string(body, buf[:m])
+			if err := sc.status_request(ssh_fxp_write, body); err != nil {
+				return n, err
+			}
+			n += int64(m)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, sc.status_request(ssh_fxp_close, sftp_string(nil, handle))
+}
@@ -1,32 +1,46 @@
 package oakUtility
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/google/goexpect"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
-	"path"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
-const (
-	AC_LITE      = "AC-LITE"
-	AC_LR        = "AC-LR"
-	AC_PRO       = "AC-PRO"
-	UBNT_ERX     = "UBNT_ERX"
-	UBNT_ERX_OLD = "ubnterx"
-)
-
 type SSHClient struct {
 	IPv4        string
 	Port        string
 	User        string
 	Pass        string
+	Banner      string // server version line, e.g. SSH-2.0-dropbear_2017.75
+	Mac         string // of the device if known, its host key is checked during handshake
+	Host_key    ssh.PublicKey
+	Refused_key *Host_key_mismatch // handshake refused a changed host key of Mac
 	timeout_sec time.Duration
 	client      *ssh.Client
+	pending     net.Conn // connected by Connect, used by next Open
+	login       Login    // of client, for Reopen
+}
+
+// Login is one credential to try: agent keys, then Key, then Pass as
+// password and keyboard-interactive answer
+type Login struct {
+	User       string `json:"user"`
+	Pass       string `json:"pass,omitempty"`
+	Key        string `json:"key,omitempty"`        // private key file
+	Passphrase string `json:"passphrase,omitempty"` // of Key, if encrypted
+	Agent      bool   `json:"agent,omitempty"`      // use ssh-agent at SSH_AUTH_SOCK
 }
 
 func New_SSHClient(host string) SSHClient {
@@ -124,100 +138,266 @@ func (c *SSHClient) One_cmd(cmd string) ([]byte, error) {
 func (c *SSHClient) SetTimeout(t time.Duration) {
 	c.timeout_sec = t
 }
-func (c *SSHClient) Open(user string, pass string) error {
-	c.User = user
-	c.Pass = pass
-	sshConfig := &ssh.ClientConfig{
-		User:            c.User,
-		Auth:            []ssh.AuthMethod{ssh.Password(c.Pass)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         c.timeout_sec,
+// replays what was read to get the banner, then the rest of the connection
+type banner_conn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (b *banner_conn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// Connect opens tcp and reads server banner, so logins can be chosen by it
+// before spending the connection on the first one
+func (c *SSHClient) Connect() error {
+	if c.pending != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.IPv4, c.Port), c.timeout_sec)
+	if err != nil {
+		return err
 	}
+	conn.SetDeadline(time.Now().Add(c.timeout_sec))
 
-	sc, e := ssh.Dial("tcp", c.IPv4+":"+c.Port, sshConfig)
-	if e == nil {
-		c.client = sc
+	// server sends its version first, maybe after some text lines (RFC 4253 4.2)
+	r := bufio.NewReader(conn)
+	var seen bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		seen.WriteString(line)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			c.Banner = strings.TrimSpace(line)
+			break
+		}
+		if seen.Len() > 8192 {
+			conn.Close()
+			return fmt.Errorf("%s:%s no ssh banner", c.IPv4, c.Port)
+		}
 	}
-	return e
+	c.pending = &banner_conn{Conn: conn, r: io.MultiReader(&seen, r)}
+	return nil
 }
-func (c *SSHClient) Close() {
-	if c.client != nil {
-		c.client.Close()
-		c.client = nil
-	}
+
+func (c *SSHClient) Open(user string, pass string) error {
+	return c.Open_login(Login{User: user, Pass: pass})
 }
-func (c *SSHClient) Scp(local string, remote string, permission string) (int64, error) {
-	f, err := os.Open(local)
+
+func expand_home(file string) string {
+	if !strings.HasPrefix(file, "~/") {
+		return file
+	}
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return 0, err
+		return file
 	}
-	defer f.Close()
+	return filepath.Join(home, file[2:])
+}
 
-	stat, err := f.Stat()
+func key_auth(file string, passphrase string) (ssh.AuthMethod, error) {
+	pem, err := ioutil.ReadFile(expand_home(file))
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		return nil, fmt.Errorf("%s: key is encrypted, passphrase needed", file)
 	}
-
-	s, err := c.client.NewSession()
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
 	}
-	defer s.Close()
+	return ssh.PublicKeys(signer), nil
+}
+
+// connection to ssh-agent, caller closes it once handshake is done
+func agent_conn() (net.Conn, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, fmt.Errorf("no ssh-agent, SSH_AUTH_SOCK not set")
+	}
+	return net.Dial("unix", sock)
+}
+
+// every question is answered with the password, what devices ask for
+func keyboard_interactive(pass string) ssh.AuthMethod {
+	return ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range answers {
+			answers[i] = pass
+		}
+		return answers, nil
+	})
+}
 
-	filename := path.Base(remote)
-	directory := path.Dir(remote)
+// keep key for whoever learns the mac later, check it now if mac is known
+func (c *SSHClient) check_host_key(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	c.Host_key = key
+	err := Verify_host_key(c.Mac, c.IPv4, key)
+	if e, ok := err.(*Host_key_mismatch); ok {
+		c.Refused_key = e
+	}
+	return err
+}
 
-	go func() {
-		w, err := s.StdinPipe()
+// Open_login opens a session with what l has of agent, key and password
+func (c *SSHClient) Open_login(l Login) error {
+	c.User = l.User
+	c.Pass = l.Pass
+	var auth []ssh.AuthMethod
+	if l.Agent {
+		ac, err := agent_conn()
 		if err != nil {
-			println(err)
-			return
+			return err
 		}
-		defer w.Close()
+		defer ac.Close()
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(ac).Signers))
+	}
+	if l.Key != "" {
+		a, err := key_auth(l.Key, l.Passphrase)
+		if err != nil {
+			return err
+		}
+		auth = append(auth, a)
+	}
+	if l.Pass != "" || (l.Key == "" && !l.Agent) {
+		auth = append(auth, ssh.Password(l.Pass), keyboard_interactive(l.Pass))
+	}
+	sshConfig := &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            auth,
+		HostKeyCallback: c.check_host_key,
+		Timeout:         c.timeout_sec,
+	}
+
+	// one tcp connection per login, server drops it after a failed one
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	conn := c.pending
+	c.pending = nil
 
-		fmt.Fprintln(w, "C"+permission, stat.Size(), filename)
-		io.Copy(w, f)
-		fmt.Fprintln(w, "\x00")
-	}()
+	addr := net.JoinHostPort(c.IPv4, c.Port)
+	sc, chans, reqs, e := ssh.NewClientConn(conn, addr, sshConfig)
+	if e != nil {
+		conn.Close()
+		return e
+	}
+	conn.SetDeadline(time.Time{})
+	c.client = ssh.NewClient(sc, chans, reqs)
+	c.login = l
+	return nil
+}
+
+// Reopen logs in again with the login of the open client, on a new connection
+func (c *SSHClient) Reopen() error {
+	if c.client == nil {
+		return fmt.Errorf("%s@%s:%s NOT connected", c.User, c.IPv4, c.Port)
+	}
+	l := c.login
+	c.Close()
+	return c.Open_login(l)
+}
 
-	s.Run("/usr/bin/scp -t " + directory)
+// Open_timeout_error is a host which took none of the logins before the deadline
+type Open_timeout_error struct {
+	IPv4   string
+	Waited time.Duration
+	Last   error // of the last try
+}
 
-	return stat.Size(), nil
+func (e *Open_timeout_error) Error() string {
+	if e.Last != nil {
+		return fmt.Sprintf("%s: no ssh login within %s, last %s", e.IPv4, e.Waited.Round(time.Second), e.Last.Error())
+	}
+	return fmt.Sprintf("%s: no ssh login within %s", e.IPv4, e.Waited.Round(time.Second))
 }
 
+const max_open_backoff = 30 * time.Second
+
+// WaitAndOpen tries creds in turn until one logs in, e.g. while the host
+// reboots. Between rounds it waits backoff, doubled each round up to 30s.
+// It returns *Open_timeout_error once deadline passes, or ctx.Err().
+func (c *SSHClient) WaitAndOpen(ctx context.Context, creds []Login, deadline time.Time, backoff time.Duration) error {
+	if len(creds) == 0 {
+		return fmt.Errorf("%s: no login to try", c.IPv4)
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	start := time.Now()
+	var last error
+	for {
+		for _, l := range creds {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !time.Now().Before(deadline) {
+				return &Open_timeout_error{IPv4: c.IPv4, Waited: time.Since(start), Last: last}
+			}
+			if last = c.Open_login(l); last == nil {
+				return nil
+			}
+		}
+		wait := time.Until(deadline)
+		if wait > backoff {
+			wait = backoff
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > max_open_backoff {
+			backoff = max_open_backoff
+		}
+	}
+}
+
+func (c *SSHClient) Close() {
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+	if c.pending != nil {
+		c.pending.Close()
+		c.pending = nil
+	}
+}
+
+// SSHFixup turns the QTS CLI login shell into ash on an open admin session.
+// dropbear picks the shell at login, only a new login gets ash
 func (c *SSHClient) SSHFixup() error {
 
 	cmd := `sed -i 's/splash/ash/g' /etc/passwd;cat /etc/passwd;sed -i 's/\(\*ash\*\)/\1|\*dropbear\*/' /lib/upgrade/common.sh;cat /lib/upgrade/common.sh`
 	promptRE := regexp.MustCompile("WLAN-AP")
 
-	if err := c.Open("admin", "admin"); err != nil {
-		fmt.Println("fail login %s: %s\n", c.IPv4, err.Error())
-		return nil
+	if c.client == nil {
+		return fmt.Errorf("%s@%s:%s NOT connected", c.User, c.IPv4, c.Port)
 	}
-	defer c.Close()
 
 	e, _, err := expect.SpawnSSH(c.client, c.timeout_sec)
 	if err != nil {
-		fmt.Println("1")
-		fmt.Println(err.Error())
 		return err
 	}
+	defer e.Close()
 
-	_, _, err1 := e.Expect(promptRE, c.timeout_sec)
-	if err1 != nil {
-		fmt.Println("2")
-		fmt.Println(err1.Error())
-		return err1
+	if _, _, err := e.Expect(promptRE, c.timeout_sec); err != nil {
+		return err
 	}
 	e.Send(cmd + "\n")
-	result, _, err2 := e.Expect(promptRE, c.timeout_sec)
-	if err2 != nil {
-		fmt.Println(err2.Error())
-		return err2
+	if _, _, err := e.Expect(promptRE, c.timeout_sec); err != nil {
+		return err
 	}
 	e.Send("exit\n")
-
-	fmt.Printf("%s: \nresult:\n %s\n\n", cmd, result)
 	return nil
 }
 
@@ -0,0 +1,211 @@
+package oakUtility
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+ * Ubiquiti discovery protocol v1, udp 10001. UniFi APs and EdgeRouters
+ * answer a 4 byte probe, also on broadcast, with a list of tlv:
+ *   header: version(1)=1 cmd(1)=0 length(2)
+ *   tlv:    type(1) length(2) value
+ */
+const UBNT_DISCOVERY_PORT = 10001
+
+var ubnt_probe = []byte{0x01, 0x00, 0x00, 0x00}
+
+const (
+	ubnt_hwaddr   = 0x01
+	ubnt_ipinfo   = 0x02
+	ubnt_firmware = 0x03
+	ubnt_hostname = 0x0b
+	ubnt_platform = 0x0c
+	ubnt_systemid = 0x10
+	ubnt_model    = 0x14
+)
+
+type Ubnt_reply struct {
+	Mac      string
+	IPv4     string
+	Firmware string
+	Hostname string
+	Platform string
+	Model    string
+	Systemid string // hex, e.g. e517 of AC-LITE
+}
+
+func Parse_ubnt_reply(pkt []byte) (*Ubnt_reply, error) {
+	if len(pkt) < 4 || pkt[0] != 0x01 || pkt[1] != 0x00 {
+		return nil, fmt.Errorf("not a ubnt discovery reply")
+	}
+	n := int(binary.BigEndian.Uint16(pkt[2:4]))
+	if n+4 > len(pkt) {
+		return nil, fmt.Errorf("short ubnt discovery reply")
+	}
+
+	r := &Ubnt_reply{}
+	body := pkt[4 : 4+n]
+	for len(body) >= 3 {
+		t := body[0]
+		l := int(binary.BigEndian.Uint16(body[1:3]))
+		if 3+l > len(body) {
+			return nil, fmt.Errorf("bad tlv %#x length %d", t, l)
+		}
+		v := body[3 : 3+l]
+		body = body[3+l:]
+
+		switch t {
+		case ubnt_hwaddr:
+			if l == 6 {
+				r.Mac = net.HardwareAddr(v).String()
+			}
+		case ubnt_ipinfo:
+			// mac + ipv4, one per interface, first one wins
+			if l == 10 && r.IPv4 == "" {
+				if r.Mac == "" {
+					r.Mac = net.HardwareAddr(v[:6]).String()
+				}
+				r.IPv4 = net.IP(v[6:10]).String()
+			}
+		case ubnt_firmware:
+			r.Firmware = string(v)
+		case ubnt_hostname:
+			r.Hostname = string(v)
+		case ubnt_platform:
+			r.Platform = string(v)
+		case ubnt_systemid:
+			r.Systemid = hex.EncodeToString(v)
+		case ubnt_model:
+			r.Model = string(v)
+		}
+	}
+	if r.Mac == "" {
+		return nil, fmt.Errorf("ubnt discovery reply without mac")
+	}
+	return r, nil
+}
+
+// Ubnt_discovery is one socket probes go out of, replies are collected
+// in the background until Collect
+type Ubnt_discovery struct {
+	conn      *net.UDPConn
+	lock      sync.Mutex
+	last_sent time.Time
+	replies   map[string]*Ubnt_reply // by mac
+	done      chan error
+}
+
+func Open_ubnt_discovery() (*Ubnt_discovery, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	u := &Ubnt_discovery{conn: conn, replies: map[string]*Ubnt_reply{}, done: make(chan error, 1), last_sent: time.Now()}
+	go u.read()
+	return u, nil
+}
+
+func (u *Ubnt_discovery) read() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := u.conn.ReadFromUDP(buf)
+		if err != nil && port_unreachable(err) {
+			continue
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				err = nil
+			}
+			u.done <- err
+			return
+		}
+		r, err := Parse_ubnt_reply(buf[:n])
+		if err != nil {
+			continue
+		}
+		if r.IPv4 == "" {
+			r.IPv4 = from.IP.String()
+		}
+		u.lock.Lock()
+		u.replies[r.Mac] = r
+		u.lock.Unlock()
+	}
+}
+
+// icmp port unreachable of a host which is not ubnt, reported on the socket
+// by a later read. WSAECONNRESET and WSAECONNREFUSED on windows.
+func port_unreachable(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return errno == 10054 || errno == 10061
+	}
+	return errno == syscall.ECONNREFUSED || errno == syscall.ECONNRESET
+}
+
+// Probe sends the probe to target, a host or a broadcast address
+func (u *Ubnt_discovery) Probe(target string) error {
+	return u.probe_addr(&net.UDPAddr{IP: net.ParseIP(target), Port: UBNT_DISCOVERY_PORT})
+}
+
+func (u *Ubnt_discovery) probe_addr(addr *net.UDPAddr) error {
+	if addr.IP == nil {
+		return fmt.Errorf("bad discovery target")
+	}
+	_, err := u.conn.WriteToUDP(ubnt_probe, addr)
+	u.lock.Lock()
+	u.last_sent = time.Now()
+	u.lock.Unlock()
+	return err
+}
+
+// Collect waits until timeout after the last probe sent and returns the
+// replies, keyed by mac. The socket is closed then.
+func (u *Ubnt_discovery) Collect(timeout time.Duration) (map[string]*Ubnt_reply, error) {
+	u.lock.Lock()
+	deadline := u.last_sent.Add(timeout)
+	u.lock.Unlock()
+	u.conn.SetReadDeadline(deadline)
+	err := <-u.done
+	u.conn.Close()
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.replies, err
+}
+
+// Ubnt_discover sends the probe to every address in targets, broadcast ones
+// included, and collects replies until timeout after the last one.
+func Ubnt_discover(targets []string, timeout time.Duration) (map[string]*Ubnt_reply, error) {
+	u, err := Open_ubnt_discovery()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		u.Probe(t)
+	}
+	return u.Collect(timeout)
+}
+
+// Broadcast_addr returns the directed broadcast of cidr
+func Broadcast_addr(cidr string) string {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil || ipnet.IP.To4() == nil {
+		return ""
+	}
+	ip := make(net.IP, 4)
+	for i := range ip {
+		ip[i] = ipnet.IP.To4()[i] | ^ipnet.Mask[i]
+	}
+	return ip.String()
+}
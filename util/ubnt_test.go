@@ -0,0 +1,165 @@
+package oakUtility
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// AC-LITE on factory firmware, in the order UniFi sends its tlv: ipinfo,
+// hwaddr, uptime, hostname, platform, firmware, version, short model,
+// default/locating/dhcpc/dhcpc bound flags, serial, sequence, required
+// firmware, systemid
+const aclite_reply = "0100009402000a788a20112233c0a801" +
+	"14010006788a201122330a000400000e" +
+	"8d0b000455424e540c000455374c5403" +
+	"0023425a2e716361393536782e76332e" +
+	"372e35382e363338352e313730353038" +
+	"2e3039353716000b332e372e35382e36" +
+	"33383515000455374c54170001011800" +
+	"0100190001011a000100130006788a20" +
+	"112233120004000000081b0006332e37" +
+	"2e3538100002e517"
+
+func aclite_packet(t *testing.T) []byte {
+	pkt, err := hex.DecodeString(aclite_reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkt
+}
+
+func TestParseUbntReply(t *testing.T) {
+	r, err := Parse_ubnt_reply(aclite_packet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Ubnt_reply{
+		Mac:      "78:8a:20:11:22:33",
+		IPv4:     "192.168.1.20",
+		Firmware: "BZ.qca956x.v3.7.58.6385.170508.0957",
+		Hostname: "UBNT",
+		Platform: "U7LT",
+		Systemid: "e517",
+	}
+	if *r != want {
+		t.Fatalf("got %+v\nwant %+v", *r, want)
+	}
+}
+
+// ipinfo alone is enough, it carries mac and ip
+func TestParseUbntReplyIpinfo(t *testing.T) {
+	pkt := []byte{0x01, 0x00, 0x00, 0x0d,
+		0x02, 0x00, 0x0a, 0x74, 0x83, 0xc2, 0x01, 0x02, 0x03, 10, 0, 0, 7}
+	r, err := Parse_ubnt_reply(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Mac != "74:83:c2:01:02:03" || r.IPv4 != "10.0.0.7" {
+		t.Fatalf("got mac %s ip %s", r.Mac, r.IPv4)
+	}
+}
+
+func TestParseUbntReplyBad(t *testing.T) {
+	good := aclite_packet(t)
+	cases := map[string][]byte{
+		"empty":          {},
+		"short header":   {0x01, 0x00, 0x00},
+		"probe":          ubnt_probe,
+		"version 2":      append([]byte{0x02}, good[1:]...),
+		"length too big": append(append([]byte{}, good[:2]...), append([]byte{0x01, 0x00}, good[4:]...)...),
+		"cut short":      good[:len(good)-10],
+		"tlv over end":   {0x01, 0x00, 0x00, 0x05, 0x01, 0x00, 0x06, 0x78, 0x8a},
+		"no mac":         {0x01, 0x00, 0x00, 0x07, 0x0b, 0x00, 0x04, 'U', 'B', 'N', 'T'},
+		"short ipinfo":   {0x01, 0x00, 0x00, 0x09, 0x02, 0x00, 0x06, 0x78, 0x8a, 0x20, 0x11, 0x22, 0x33},
+	}
+	for name, pkt := range cases {
+		if r, err := Parse_ubnt_reply(pkt); err == nil {
+			t.Errorf("%s: accepted as %+v", name, *r)
+		}
+	}
+}
+
+// a device on loopback answering the probe, plus noise which must be ignored
+func TestUbntDiscoverLoopback(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: UBNT_DISCOVERY_PORT})
+	if err != nil {
+		t.Skipf("udp %d not free: %s", UBNT_DISCOVERY_PORT, err.Error())
+	}
+	defer conn.Close()
+	reply := aclite_packet(t)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(buf[:n], ubnt_probe) {
+				continue
+			}
+			conn.WriteToUDP([]byte("not ubnt"), from)
+			conn.WriteToUDP(reply, from)
+		}
+	}()
+
+	start := time.Now()
+	replies, err := Ubnt_discover([]string{"127.0.0.1", "not an ip"}, 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 400*time.Millisecond || waited > 2*time.Second {
+		t.Errorf("collected for %s, want about the timeout", waited)
+	}
+	r, ok := replies["78:8a:20:11:22:33"]
+	if !ok || len(replies) != 1 {
+		t.Fatalf("replies %v", replies)
+	}
+	if r.Systemid != "e517" || r.IPv4 != "192.168.1.20" {
+		t.Fatalf("got %+v", *r)
+	}
+}
+
+// replies count from the last probe, not from the first
+func TestUbntDiscoveryWaitsAfterLastProbe(t *testing.T) {
+	u, err := Open_ubnt_discovery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Probe("127.0.0.1")
+	time.Sleep(300 * time.Millisecond)
+	u.Probe("127.0.0.1")
+	start := time.Now()
+	if _, err := u.Collect(300 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 250*time.Millisecond {
+		t.Errorf("collected for %s after the last probe, want the timeout", waited)
+	}
+}
+
+// a host without discovery answers with icmp port unreachable, the read
+// which reports it must not end collecting
+func TestPortUnreachable(t *testing.T) {
+	refused, reset := syscall.ECONNREFUSED, syscall.ECONNRESET
+	if runtime.GOOS == "windows" {
+		refused, reset = syscall.Errno(10061), syscall.Errno(10054)
+	}
+	for _, errno := range []syscall.Errno{refused, reset} {
+		err := &net.OpError{Op: "read", Net: "udp4", Err: os.NewSyscallError("recvfrom", errno)}
+		if !port_unreachable(err) {
+			t.Errorf("%v not taken as port unreachable", err)
+		}
+	}
+	timeout := &net.OpError{Op: "read", Net: "udp4", Err: os.ErrDeadlineExceeded}
+	for _, err := range []error{timeout, net.ErrClosed, os.NewSyscallError("recvfrom", syscall.EBADF)} {
+		if port_unreachable(err) {
+			t.Errorf("%v taken as port unreachable", err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package oakUtility
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+/*
+ * every image directory on the server carries a SHA256SUMS next to the image,
+ * same format as sha256sum output: "<hex>  <file name>" per line.
+ * SHA256SUMS.sig, if present, is an ed25519 signature over SHA256SUMS
+ */
+const (
+	MANIFEST_NAME  = "SHA256SUMS"
+	SIGNATURE_NAME = "SHA256SUMS.sig"
+)
+
+var Verify_images = true          // false skips checksum, only for servers without manifest
+var Signing_key ed25519.PublicKey // if set, manifest must be signed by it
+
+var manifest_cache = map[string]map[string]string{}
+var manifest_lock sync.Mutex
+
+func Parse_signing_key(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("signing key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// fetch a small file, from bundle or the first image server which has it
+func http_get_bytes(ref string) ([]byte, error) {
+	if bundle != nil {
+		return bundle_get_bytes(ref)
+	}
+	urls := Image_urls(ref)
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("%s: no image server", ref)
+	}
+	var err error
+	for _, u := range urls {
+		var data []byte
+		if data, err = get_bytes(u); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+func get_bytes(url string) ([]byte, error) {
+	if is_file_url(url) {
+		return ioutil.ReadFile(file_url_path(url))
+	}
+	resp, err := http_client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// same directory as image url
+func sibling_url(url string, name string) string {
+	return url[:strings.LastIndex(url, "/")+1] + name
+}
+
+func Manifest_url(url string) string {
+	return sibling_url(url, MANIFEST_NAME)
+}
+
+func Signature_url(url string) string {
+	return sibling_url(url, SIGNATURE_NAME)
+}
+
+// Get_manifest returns file name to sha256 of the directory which url is in
+func Get_manifest(url string) (map[string]string, error) {
+	murl := sibling_url(url, MANIFEST_NAME)
+
+	manifest_lock.Lock()
+	defer manifest_lock.Unlock()
+	if m, ok := manifest_cache[murl]; ok {
+		return m, nil
+	}
+
+	data, err := http_get_bytes(murl)
+	if err != nil {
+		return nil, err
+	}
+
+	if Signing_key != nil {
+		sig, err := http_get_bytes(sibling_url(url, SIGNATURE_NAME))
+		if err != nil {
+			return nil, err
+		}
+		if err := Verify_signature(data, sig, Signing_key); err != nil {
+			return nil, fmt.Errorf("%s: %s", murl, err.Error())
+		}
+	}
+
+	m := Parse_manifest(data)
+	manifest_cache[murl] = m
+	return m, nil
+}
+
+// signature file can be raw 64 bytes or base64 text
+func Verify_signature(data []byte, sig []byte, key ed25519.PublicKey) error {
+	if len(sig) != ed25519.SignatureSize {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("bad signature encoding: %s", err.Error())
+		}
+		sig = raw
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func Parse_manifest(data []byte) map[string]string {
+	m := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 {
+			continue
+		}
+		m[strings.TrimPrefix(f[1], "*")] = strings.ToLower(f[0]) // "*" is sha256sum binary mode
+	}
+	return m
+}
+
+func Sha256_file(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func Verify_file(localfile string, sum string) error {
+	got, err := Sha256_file(localfile)
+	if err != nil {
+		return err
+	}
+	if got != sum {
+		return fmt.Errorf("%s: sha256 %s, expect %s", localfile, got, sum)
+	}
+	return nil
+}
+
+// sha256 of url from the manifest next to it
+func image_sum(url string) (string, error) {
+	m, err := Get_manifest(url)
+	if err != nil {
+		return "", fmt.Errorf("no manifest for %s: %s", url, err.Error())
+	}
+	sum, ok := m[path.Base(url)]
+	if !ok {
+		return "", fmt.Errorf("%s not in %s", path.Base(url), sibling_url(url, MANIFEST_NAME))
+	}
+	return sum, nil
+}
+
+// Verify_image checks an already downloaded localfile against manifest of url
+func Verify_image(localfile string, url string) error {
+	sum, err := image_sum(url)
+	if err != nil {
+		return err
+	}
+	return Verify_file(localfile, sum)
+}
+
+// Download_image is On_demand_download plus checksum, a cached file which
+// does not match manifest is thrown away and fetched again
+func Download_image(localfile string, url string) error {
+	return Download_image_to(localfile, url, nil)
+}
+
+// Download_image_to is Download_image with progress and messages on rep
+func Download_image_to(localfile string, url string, rep Reporter) error {
+	if !Verify_images {
+		unlock := lock_file(localfile)
+		defer unlock()
+		return on_demand_download(localfile, url, rep)
+	}
+
+	sum, err := image_sum(url)
+	if err != nil {
+		return err
+	}
+
+	unlock := lock_file(localfile)
+	defer unlock()
+
+	if _, err := os.Stat(localfile); err == nil {
+		if Verify_file(localfile, sum) == nil {
+			return nil
+		}
+		say(rep, "%s is stale or damaged, download again\n", localfile)
+		os.Remove(localfile)
+	}
+
+	// a resumed .tmp may belong to an older image, so one more try from scratch
+	for try := 0; ; try++ {
+		if err := on_demand_download(localfile, url, rep); err != nil {
+			return err
+		}
+		err := Verify_file(localfile, sum)
+		if err == nil {
+			return nil
+		}
+		os.Remove(localfile)
+		if try > 0 {
+			return err
+		}
+		say(rep, "%s, download again\n", err.Error())
+	}
+}